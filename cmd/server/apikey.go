@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+const apiKeyUsage = `Usage: bookies apikey <command>
+
+Commands:
+  create [-roles ROLES] [-expires DURATION] NAME
+                      create API key, ROLES is comma separated list of
+                      reader, editor and admin, the key is only printed once
+  list                list API keys
+  revoke PREFIX       revoke API key by its prefix`
+
+// runAPIKey handle apikey subcommand
+func runAPIKey(ctx context.Context, repo repository.APIKeyRepository, args []string) error {
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		return createAPIKey(ctx, repo, args[1:])
+
+	case "list":
+		keys, err := repo.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PREFIX\tNAME\tROLES\tCREATED\tEXPIRES\tLAST USED\tREVOKED")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.Prefix, k.Name, strings.Join(k.Roles, ","),
+				k.CreatedAt.Format(time.DateTime), formatTime(k.ExpiresAt), formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+		}
+		w.Flush()
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: bookies apikey revoke PREFIX")
+		}
+
+		key := &models.APIKeyDBModel{Prefix: args[1]}
+		if err := repo.Revoke(ctx, key); errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("unknown or already revoked API key")
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("API key %s revoked\n", key.Prefix)
+
+	default:
+		return errors.New(apiKeyUsage)
+	}
+
+	return nil
+}
+
+// createAPIKey handle apikey create subcommand
+func createAPIKey(ctx context.Context, repo repository.APIKeyRepository, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	roles := fs.String("roles", "", "comma separated roles granted to the key")
+	expires := fs.Duration("expires", 0, "key lifetime, 0 never expires")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: bookies apikey create [-roles ROLES] [-expires DURATION] NAME")
+	}
+
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	key := &models.APIKeyDBModel{Name: fs.Arg(0), Prefix: prefix, KeyHash: hash, Roles: []string{}}
+	if *roles != "" {
+		key.Roles = strings.Split(*roles, ",")
+	}
+	for _, role := range key.Roles {
+		if !auth.ValidRole(role) {
+			return fmt.Errorf("unknown role %q, use reader, editor or admin", role)
+		}
+	}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := repo.Insert(ctx, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("API key %s created, store it now, it can not be shown again\n%s\n", key.Prefix, secret)
+	return nil
+}
+
+// formatTime format optional time for listing
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.DateTime)
+}
@@ -3,16 +3,29 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 
 	"github.com/kasfil/bookies/pkg/app"
+	"github.com/kasfil/bookies/pkg/auth"
 	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/handlers"
+	"github.com/kasfil/bookies/pkg/health"
+	"github.com/kasfil/bookies/pkg/jobs"
+	"github.com/kasfil/bookies/pkg/migrate"
+	"github.com/kasfil/bookies/pkg/ratelimit"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/storage"
+	"github.com/kasfil/bookies/pkg/utilities"
 	"github.com/kasfil/bookies/pkg/validators"
 )
 
@@ -23,8 +36,19 @@ func main() {
 		log.Fatal("Unable to find .env file")
 	}
 
+	autoMigrate := flag.Bool("auto-migrate", utilities.EnvBool("AUTO_MIGRATE", false), "apply pending migrations before serving")
+	flag.Parse()
+
 	ctx := context.Background()
 
+	// bookies migrate <command>
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Check database connection by create connection pool
 	dbconn, err := database.GetConnection(ctx)
 	if err != nil {
@@ -37,12 +61,49 @@ func main() {
 		log.Fatal("Failed to connect database", err)
 	}
 
+	if *autoMigrate {
+		migrator, err := migrate.New(dbconn)
+		if err != nil {
+			log.Fatal("Failed to load migrations ", err)
+		}
+
+		applied, err := migrator.Up(ctx)
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			log.Fatal("Failed to migrate database ", err)
+		}
+		log.Printf("%d migration(s) applied", applied)
+	}
+
 	// Register custom validator
 	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		validate.RegisterValidation("validname", validators.ValidName)
+		validate.RegisterValidation("validisbn", validators.ValidISBN)
+		validate.RegisterValidation("validslug", validators.ValidSlug)
+	}
+
+	repos := repository.NewPgxRepositories(dbconn)
+
+	// bookies apikey <command>
+	if flag.Arg(0) == "apikey" {
+		if err := runAPIKey(ctx, repos.APIKey, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	jwtVerifier, err := auth.NewJWTVerifierFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load JWT keys ", err)
+	}
+
+	store, err := storage.NewStorageFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure storage ", err)
 	}
 
-	app := app.CreateRestApp()
+	probe := health.NewProbe(dbconn)
+	limiter := ratelimit.NewLimiterFromEnv(ratelimit.NewMemoryStore(), handlers.RouteGroups...)
+	restApp := app.CreateRestApp(repos, store, probe, auth.New(repos.APIKey, jwtVerifier), limiter)
 
 	host := os.Getenv("APP_HOST")
 	if host == "" {
@@ -55,5 +116,19 @@ func main() {
 	}
 
 	addr := fmt.Sprintf("%s:%s", host, port)
-	app.Run(addr)
+	server := app.NewServer(addr, restApp, probe)
+
+	// Stop serving on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Permanently remove expired trash in background
+	go jobs.NewPurgeJob(repos).Run(ctx)
+
+	if err := server.Run(ctx); err != nil {
+		log.Println(err)
+	}
+
+	// Release all database connections once every request is done
+	dbconn.Close()
 }
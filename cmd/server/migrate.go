@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/migrate"
+)
+
+const migrateUsage = `Usage: bookies migrate <command>
+
+Commands:
+  up                  apply all pending migrations
+  down N              revert N latest applied migrations
+  status              list migrations and their applied state
+  force VERSION       set version without running migration and clear dirty
+                      state, use "none" to mark database as never migrated
+  create [-dir DIR] NAME
+                      create empty up and down migration files in DIR
+                      (default "migrations")`
+
+// runMigrate handle migrate subcommand
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	// create only write files, no database needed
+	if args[0] == "create" {
+		return createMigration(args[1:])
+	}
+
+	db, err := database.GetConnection(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("no pending migration")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) applied\n", applied)
+
+	case "down":
+		if len(args) != 2 {
+			return errors.New("usage: bookies migrate down N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return errors.New("N should be number and greater than 0")
+		}
+
+		reverted, err := migrator.Down(ctx, n)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("no applied migration")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) reverted\n", reverted)
+
+	case "status":
+		return printStatus(ctx, migrator)
+
+	case "force":
+		if len(args) != 2 {
+			return errors.New("usage: bookies migrate force VERSION")
+		}
+
+		var version *uint64
+		if args[1] != "none" {
+			v, err := strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return errors.New("VERSION should be migration version or none")
+			}
+			version = &v
+		}
+
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("version forced to %s\n", args[1])
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+// printStatus print migrations table with applied state
+func printStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	version, dirty, ok, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range list {
+		fmt.Fprintf(w, "%d\t%s\t%t\n", s.Version, s.Name, s.Applied)
+	}
+	w.Flush()
+
+	switch {
+	case !ok:
+		fmt.Println("\ndatabase not migrated")
+	case dirty:
+		fmt.Printf("\ncurrent version %d (dirty)\n", version)
+	default:
+		fmt.Printf("\ncurrent version %d\n", version)
+	}
+
+	return nil
+}
+
+// createMigration handle migrate create subcommand
+func createMigration(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	dir := fs.String("dir", "migrations", "migrations directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: bookies migrate create [-dir DIR] NAME")
+	}
+
+	up, down, err := migrate.Create(*dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("created", up)
+	fmt.Println("created", down)
+	return nil
+}
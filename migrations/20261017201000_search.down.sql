@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS authors_search_idx;
+ALTER TABLE authors DROP COLUMN IF EXISTS search;
+
+DROP INDEX IF EXISTS books_search_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS search;
@@ -0,0 +1,13 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS books_search_idx ON books USING GIN (search);
+
+ALTER TABLE authors ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(bio, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS authors_search_idx ON authors USING GIN (search);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS book_contributors (
+    book_id integer NOT NULL,
+    author_id integer NOT NULL,
+    role varchar(32) NOT NULL DEFAULT 'author',
+    position smallint NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role),
+    CONSTRAINT book_contributors_book FOREIGN KEY(book_id) REFERENCES books(id) ON DELETE CASCADE,
+    CONSTRAINT book_contributors_author FOREIGN KEY(author_id) REFERENCES authors(id) ON DELETE CASCADE,
+    CONSTRAINT book_contributors_role CHECK (role IN ('author', 'co_author', 'editor', 'translator', 'illustrator'))
+);
+
+CREATE INDEX IF NOT EXISTS book_contributors_author_idx ON book_contributors (author_id);
+
+-- books.author_id stays as the primary author, every existing book get it as contributor
+INSERT INTO book_contributors (book_id, author_id, role, position)
+SELECT id, author_id, 'author', 0 FROM books
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_deleted_at_idx;
+DROP INDEX IF EXISTS authors_deleted_at_idx;
+
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE authors DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE authors ADD COLUMN IF NOT EXISTS deleted_at timestamptz NULL;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at timestamptz NULL;
+
+-- trash listing and purge job only look at deleted rows
+CREATE INDEX IF NOT EXISTS authors_deleted_at_idx ON authors (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS books_deleted_at_idx ON books (deleted_at) WHERE deleted_at IS NOT NULL;
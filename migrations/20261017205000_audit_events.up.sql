@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY,
+    occurred_at timestamptz NOT NULL DEFAULT now(),
+    actor varchar(128) NOT NULL,
+    action varchar(16) NOT NULL,
+    entity varchar(16) NOT NULL,
+    entity_id integer NOT NULL,
+    request_id varchar(128) NULL,
+    -- changed fields only, {"field": {"before": ..., "after": ...}}
+    changes jsonb NOT NULL DEFAULT '{}'::jsonb
+);
+
+-- entity history, newest first
+CREATE INDEX IF NOT EXISTS audit_events_entity_idx ON audit_events (entity, entity_id, id DESC);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id serial PRIMARY KEY,
+    name varchar(64) NOT NULL,
+    -- public part of the key used to look it up, secret part is only stored hashed
+    prefix varchar(16) NOT NULL UNIQUE,
+    key_hash bytea NOT NULL,
+    roles text[] NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz NULL,
+    last_used_at timestamptz NULL,
+    revoked_at timestamptz NULL
+);
@@ -0,0 +1,3 @@
+-- optimistic concurrency, every change of record increase its version
+ALTER TABLE authors ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
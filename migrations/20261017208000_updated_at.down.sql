@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS book_contributors_touch ON book_contributors;
+DROP TRIGGER IF EXISTS books_touch_authors ON books;
+DROP TRIGGER IF EXISTS authors_touch_books ON authors;
+DROP TRIGGER IF EXISTS books_updated_at ON books;
+DROP TRIGGER IF EXISTS authors_updated_at ON authors;
+
+DROP FUNCTION IF EXISTS touch_contribution();
+DROP FUNCTION IF EXISTS touch_book_authors();
+DROP FUNCTION IF EXISTS touch_author_books();
+DROP FUNCTION IF EXISTS touch_updated_at();
+
+ALTER TABLE books DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE authors DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,63 @@
+-- updated_at tells when the API representation of the record last changed,
+-- including related records shown within it: author name in books and book
+-- total of authors
+ALTER TABLE authors ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE books ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+CREATE OR REPLACE FUNCTION touch_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = clock_timestamp();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER authors_updated_at BEFORE UPDATE ON authors
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TRIGGER books_updated_at BEFORE UPDATE ON books
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+-- author is shown in every book it contributed to
+CREATE OR REPLACE FUNCTION touch_author_books() RETURNS trigger AS $$
+BEGIN
+    UPDATE books SET updated_at = clock_timestamp()
+    WHERE author_id = NEW.id
+        OR id IN (SELECT book_id FROM book_contributors WHERE author_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER authors_touch_books AFTER UPDATE OF name, email, birth_date, bio, deleted_at ON authors
+FOR EACH ROW EXECUTE FUNCTION touch_author_books();
+
+-- deleted books are left out of author book total
+CREATE OR REPLACE FUNCTION touch_book_authors() RETURNS trigger AS $$
+BEGIN
+    UPDATE authors SET updated_at = clock_timestamp()
+    WHERE id IN (SELECT author_id FROM book_contributors WHERE book_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_touch_authors AFTER UPDATE OF deleted_at ON books
+FOR EACH ROW EXECUTE FUNCTION touch_book_authors();
+
+-- contribution change both book contributors and author book total
+CREATE OR REPLACE FUNCTION touch_contribution() RETURNS trigger AS $$
+DECLARE
+    changed book_contributors%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    UPDATE authors SET updated_at = clock_timestamp() WHERE id = changed.author_id;
+    UPDATE books SET updated_at = clock_timestamp() WHERE id = changed.book_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER book_contributors_touch AFTER INSERT OR UPDATE OR DELETE ON book_contributors
+FOR EACH ROW EXECUTE FUNCTION touch_contribution();
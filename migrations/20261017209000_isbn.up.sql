@@ -0,0 +1,6 @@
+-- isbn is always stored as ISBN-13, ISBN-10 is converted by the API. Deleted
+-- books are left out of uniqueness so the same edition can be added again.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn char(13)
+    CONSTRAINT books_isbn_check CHECK (isbn ~ '^97[89][0-9]{10}$');
+
+CREATE UNIQUE INDEX IF NOT EXISTS books_isbn_key ON books (isbn) WHERE deleted_at IS NULL;
@@ -0,0 +1,15 @@
+DROP TRIGGER IF EXISTS books_touch_publishers ON books;
+DROP TRIGGER IF EXISTS publishers_touch_books ON publishers;
+DROP TRIGGER IF EXISTS publishers_updated_at ON publishers;
+DROP FUNCTION IF EXISTS touch_book_publishers();
+DROP FUNCTION IF EXISTS touch_publisher_books();
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS work_id,
+    DROP COLUMN IF EXISTS format,
+    DROP COLUMN IF EXISTS page_count,
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS publisher_id;
+
+DROP TABLE IF EXISTS works;
+DROP TABLE IF EXISTS publishers;
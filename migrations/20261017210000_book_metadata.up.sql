@@ -0,0 +1,65 @@
+CREATE TABLE IF NOT EXISTS publishers (
+    id serial PRIMARY KEY,
+    name varchar(128) NOT NULL,
+    website varchar(256) NULL,
+    version integer NOT NULL DEFAULT 1,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- publisher names are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS publishers_name_key ON publishers (lower(name));
+
+CREATE TRIGGER publishers_updated_at BEFORE UPDATE ON publishers
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+-- publisher name is shown in its books
+CREATE OR REPLACE FUNCTION touch_publisher_books() RETURNS trigger AS $$
+BEGIN
+    UPDATE books SET updated_at = clock_timestamp() WHERE publisher_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- work groups every edition of the same book, each book is an edition
+CREATE TABLE IF NOT EXISTS works (
+    id serial PRIMARY KEY,
+    title varchar(128) NOT NULL
+);
+
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS publisher_id integer NULL
+        CONSTRAINT books_publisher_id_fkey REFERENCES publishers(id) ON DELETE RESTRICT,
+    ADD COLUMN IF NOT EXISTS language varchar(35) NULL,
+    ADD COLUMN IF NOT EXISTS page_count integer NULL CONSTRAINT books_page_count_check CHECK (page_count > 0),
+    ADD COLUMN IF NOT EXISTS format varchar(16) NULL
+        CONSTRAINT books_format_check CHECK (format IN ('hardcover', 'paperback', 'ebook', 'audio')),
+    ADD COLUMN IF NOT EXISTS work_id integer NULL
+        CONSTRAINT books_work_id_fkey REFERENCES works(id) ON DELETE RESTRICT;
+
+-- every existing book is the single edition of its own work
+INSERT INTO works (id, title) SELECT id, title FROM books;
+SELECT setval(pg_get_serial_sequence('works', 'id'), (SELECT COALESCE(max(id), 0) + 1 FROM works), false);
+UPDATE books SET work_id = id;
+ALTER TABLE books ALTER COLUMN work_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS books_publisher_id_idx ON books (publisher_id);
+CREATE INDEX IF NOT EXISTS books_work_id_idx ON books (work_id);
+
+CREATE TRIGGER publishers_touch_books AFTER UPDATE OF name ON publishers
+FOR EACH ROW EXECUTE FUNCTION touch_publisher_books();
+
+-- publisher book total follow its active books
+CREATE OR REPLACE FUNCTION touch_book_publishers() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE publishers SET updated_at = clock_timestamp() WHERE id = OLD.publisher_id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        UPDATE publishers SET updated_at = clock_timestamp() WHERE id = NEW.publisher_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_touch_publishers AFTER INSERT OR UPDATE OF publisher_id, deleted_at OR DELETE ON books
+FOR EACH ROW EXECUTE FUNCTION touch_book_publishers();
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS genres_touch_books ON genres;
+DROP TRIGGER IF EXISTS genres_updated_at ON genres;
+DROP FUNCTION IF EXISTS touch_genre_books();
+
+DROP TABLE IF EXISTS book_tags;
+DROP TABLE IF EXISTS book_genres;
+DROP TABLE IF EXISTS genres;
@@ -0,0 +1,43 @@
+-- genres form a tree through parent_id, slug is used to refer to them
+CREATE TABLE IF NOT EXISTS genres (
+    id serial PRIMARY KEY,
+    slug varchar(64) NOT NULL UNIQUE,
+    name varchar(64) NOT NULL,
+    parent_id integer NULL REFERENCES genres(id) ON DELETE RESTRICT,
+    version integer NOT NULL DEFAULT 1,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS genres_parent_id_idx ON genres (parent_id);
+
+CREATE TRIGGER genres_updated_at BEFORE UPDATE ON genres
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TABLE IF NOT EXISTS book_genres (
+    book_id integer NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    genre_id integer NOT NULL REFERENCES genres(id) ON DELETE RESTRICT,
+    PRIMARY KEY (book_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_genres_genre_id_idx ON book_genres (genre_id);
+
+-- free-form tags, always stored lower case
+CREATE TABLE IF NOT EXISTS book_tags (
+    book_id integer NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    tag varchar(32) NOT NULL,
+    PRIMARY KEY (book_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS book_tags_tag_idx ON book_tags (tag);
+
+-- genre slug and name are shown in its books
+CREATE OR REPLACE FUNCTION touch_genre_books() RETURNS trigger AS $$
+BEGIN
+    UPDATE books SET updated_at = clock_timestamp()
+    WHERE id IN (SELECT book_id FROM book_genres WHERE genre_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER genres_touch_books AFTER UPDATE OF slug, name ON genres
+FOR EACH ROW EXECUTE FUNCTION touch_genre_books();
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS books_touch_series ON books;
+DROP TRIGGER IF EXISTS series_touch_books ON series;
+DROP TRIGGER IF EXISTS series_updated_at ON series;
+DROP FUNCTION IF EXISTS touch_book_series();
+DROP FUNCTION IF EXISTS touch_series_books();
+DROP INDEX IF EXISTS books_series_position_key;
+
+ALTER TABLE books
+    DROP CONSTRAINT IF EXISTS books_series_check,
+    DROP COLUMN IF EXISTS series_position,
+    DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS series;
@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS series (
+    id serial PRIMARY KEY,
+    name varchar(128) NOT NULL,
+    description text NULL,
+    version integer NOT NULL DEFAULT 1,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- series names are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS series_name_key ON series (lower(name));
+
+CREATE TRIGGER series_updated_at BEFORE UPDATE ON series
+FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+-- book belongs to at most one series, position is its place in reading
+-- order and may be fractional such as 1.5 for a novella between 1 and 2
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS series_id integer NULL
+        CONSTRAINT books_series_id_fkey REFERENCES series(id) ON DELETE RESTRICT,
+    ADD COLUMN IF NOT EXISTS series_position numeric(8, 3) NULL
+        CONSTRAINT books_series_position_check CHECK (series_position > 0),
+    ADD CONSTRAINT books_series_check CHECK ((series_id IS NULL) = (series_position IS NULL));
+
+-- active books of a series do not share position
+CREATE UNIQUE INDEX IF NOT EXISTS books_series_position_key ON books (series_id, series_position)
+WHERE deleted_at IS NULL AND series_id IS NOT NULL;
+
+-- series name is shown in its books
+CREATE OR REPLACE FUNCTION touch_series_books() RETURNS trigger AS $$
+BEGIN
+    UPDATE books SET updated_at = clock_timestamp() WHERE series_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER series_touch_books AFTER UPDATE OF name ON series
+FOR EACH ROW EXECUTE FUNCTION touch_series_books();
+
+-- series book total follow its active books
+CREATE OR REPLACE FUNCTION touch_book_series() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE series SET updated_at = clock_timestamp() WHERE id = OLD.series_id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        UPDATE series SET updated_at = clock_timestamp() WHERE id = NEW.series_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_touch_series AFTER INSERT OR UPDATE OF series_id, deleted_at OR DELETE ON books
+FOR EACH ROW EXECUTE FUNCTION touch_book_series();
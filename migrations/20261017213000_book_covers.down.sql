@@ -0,0 +1,6 @@
+ALTER TABLE books
+    DROP CONSTRAINT IF EXISTS books_cover_check,
+    DROP COLUMN IF EXISTS cover_thumbnail_url,
+    DROP COLUMN IF EXISTS cover_url,
+    DROP COLUMN IF EXISTS cover_thumbnail_key,
+    DROP COLUMN IF EXISTS cover_key;
@@ -0,0 +1,8 @@
+-- cover image objects in storage along with their public URL, set together
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS cover_key varchar(256) NULL,
+    ADD COLUMN IF NOT EXISTS cover_thumbnail_key varchar(256) NULL,
+    ADD COLUMN IF NOT EXISTS cover_url text NULL,
+    ADD COLUMN IF NOT EXISTS cover_thumbnail_url text NULL,
+    ADD CONSTRAINT books_cover_check
+        CHECK (num_nulls(cover_key, cover_thumbnail_key, cover_url, cover_thumbnail_url) IN (0, 4));
@@ -0,0 +1,82 @@
+// Package migrations Embedded database schema migrations
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FS all migration files, named {version}_{title}.{up|down}.sql
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Migration single schema version with its up and down script file name
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// List get every embedded migration ordered by version
+func List() ([]Migration, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, file := range files {
+		version, name, direction, err := parseFileName(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = file
+		} else {
+			m.Down = file
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+// parseFileName split "20241231075100_initial.up.sql" into its parts
+func parseFileName(file string) (uint64, string, string, error) {
+	base := strings.TrimSuffix(file, ".sql")
+	dot := strings.LastIndex(base, ".")
+	underscore := strings.Index(base, "_")
+	if dot < 0 || underscore < 0 || underscore > dot {
+		return 0, "", "", fmt.Errorf("invalid migration file name %s", file)
+	}
+
+	direction := base[dot+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("invalid migration direction in %s", file)
+	}
+
+	version, err := strconv.ParseUint(base[:underscore], 10, 64)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid migration version in %s", file)
+	}
+
+	return version, base[underscore+1 : dot], direction, nil
+}
@@ -0,0 +1,102 @@
+// Package app Provide builder for core application instance
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/health"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// Server http server wrapper which drain in-flight requests on shutdown
+type Server struct {
+	// ShutdownDelay time to keep serving after readiness turn unhealthy,
+	// give load balancer chance to stop routing traffic here
+	ShutdownDelay time.Duration
+	// DrainTimeout maximum time to wait in-flight requests to finish
+	DrainTimeout time.Duration
+
+	server   *http.Server
+	probe    *health.Probe
+	inFlight atomic.Int64
+}
+
+// NewServer create server listening on addr serving the rest app
+func NewServer(addr string, app *gin.Engine, probe *health.Probe) *Server {
+	s := &Server{
+		ShutdownDelay: utilities.EnvDuration("SHUTDOWN_DELAY", 0),
+		DrainTimeout:  utilities.EnvDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second),
+		probe:         probe,
+	}
+	s.server = &http.Server{
+		Addr:    addr,
+		Handler: s.track(app.Handler()),
+	}
+
+	return s
+}
+
+// InFlight number of requests currently being served
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// track count requests being served by handler
+func (s *Server) track(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Run serve requests until ctx is done, then shutdown gracefully
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Listening and serving HTTP on %s", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	return s.Shutdown()
+}
+
+// Shutdown flip readiness to unhealthy, stop accepting new connection and
+// wait in-flight requests up to DrainTimeout
+func (s *Server) Shutdown() error {
+	s.probe.SetDraining(true)
+	if s.ShutdownDelay > 0 {
+		log.Printf("Readiness set to draining, waiting %s before shutdown", s.ShutdownDelay)
+		time.Sleep(s.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.DrainTimeout)
+	defer cancel()
+
+	log.Printf("Shutting down, draining %d in-flight requests", s.InFlight())
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Printf("Drain timeout reached, dropping %d in-flight requests", s.InFlight())
+		s.server.Close()
+		return err
+	}
+
+	log.Println("Server stopped")
+	return nil
+}
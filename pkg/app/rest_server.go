@@ -2,16 +2,59 @@
 package app
 
 import (
+	"log"
+	"time"
+
 	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/auth"
 	"github.com/kasfil/bookies/pkg/handlers"
+	"github.com/kasfil/bookies/pkg/health"
+	"github.com/kasfil/bookies/pkg/middleware"
+	"github.com/kasfil/bookies/pkg/ratelimit"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/storage"
+	"github.com/kasfil/bookies/pkg/utilities"
 )
 
-// CreateRestApp Main rest server builder
-func CreateRestApp() *gin.Engine {
+// CreateRestApp Main rest server builder, every handler is served by the
+// given repositories and store, authenticated by authn and throttled by
+// limiter
+func CreateRestApp(repos *repository.Repositories, store storage.Storage, probe *health.Probe, authn *auth.Auth, limiter *ratelimit.Limiter) *gin.Engine {
 	app := gin.Default()
 
+	// client IP (rate limit key of anonymous requests) is only taken from
+	// X-Forwarded-For of requests coming through TRUSTED_PROXIES
+	if err := app.SetTrustedProxies(utilities.EnvList("TRUSTED_PROXIES")); err != nil {
+		log.Println("invalid TRUSTED_PROXIES, trusting no proxy", err)
+		app.SetTrustedProxies(nil)
+	}
+
+	// trace every request, the ID is recorded on audit events
+	app.Use(middleware.RequestID())
+
+	// bound every request database work, QUERY_TIMEOUT=0 disable it. Export
+	// streams the whole catalog and has its own bound.
+	app.Use(middleware.QueryTimeout(
+		utilities.EnvDuration("QUERY_TIMEOUT", 10*time.Second),
+		middleware.RouteTimeout{Prefix: "/export/", Timeout: utilities.EnvDuration("EXPORT_TIMEOUT", 10*time.Minute)},
+	))
+
+	// orchestrator probes
+	app.GET("/healthz", probe.Liveness)
+	app.GET("/readyz", probe.Readiness)
+
+	// files of local storage are public like objects of a public bucket
+	if local, ok := store.(*storage.LocalStorage); ok {
+		app.Static(local.Route, local.Dir)
+	}
+
+	// throttle credentials by client IP before they are checked, then attach
+	// principal of API key or bearer token to every API request
+	app.Use(limiter.Authentication(), authn.Middleware())
+
 	// include all controllers
-	handlers.IncludeHandlers(app)
+	handlers.IncludeHandlers(app, repos, store, authn, limiter)
 
 	return app
 }
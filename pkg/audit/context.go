@@ -0,0 +1,45 @@
+// Package audit Request scoped actor and request ID recorded on audit events
+package audit
+
+import "context"
+
+// Anonymous actor recorded when request is not authenticated
+const Anonymous = "anonymous"
+
+// System actor recorded for changes made by background jobs
+const System = "system"
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+)
+
+// WithActor attach actor to ctx
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFrom get actor attached to ctx, Anonymous when none
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+
+	return Anonymous
+}
+
+// WithRequestID attach request ID to ctx
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFrom get request ID attached to ctx, nil when none
+func RequestIDFrom(ctx context.Context) *string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		return &id
+	}
+
+	return nil
+}
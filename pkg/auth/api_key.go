@@ -0,0 +1,111 @@
+// Package auth API key and JWT bearer token authentication
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// APIKeyPrefix every API key start with this prefix, it tells API key apart
+// from JWT in Authorization header
+const APIKeyPrefix = "bk_"
+
+// touchInterval how long API key usage is not recorded again after it was
+// last recorded
+const touchInterval = time.Minute
+
+// ErrInvalidCredentials tells that given credentials are unknown, expired,
+// revoked or malformed
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// GenerateAPIKey create new random API key in form bk_<prefix>_<secret>.
+// The prefix is stored in plain text to look up the key, only hash of the
+// full key is stored.
+func GenerateAPIKey() (key, prefix string, hash []byte, err error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", nil, err
+	}
+
+	prefix = hex.EncodeToString(id)
+	key = APIKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey hash of API key stored in database. Key has 256 bit of entropy
+// so plain SHA-256 is enough, slow password hash is not needed.
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// parseAPIKey get prefix part of API key
+func parseAPIKey(key string) (string, bool) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, APIKeyPrefix) || prefix == "" {
+		return "", false
+	}
+
+	return prefix, true
+}
+
+// APIKeyAuthenticator authenticate API key against keys stored in database
+type APIKeyAuthenticator struct {
+	repo repository.APIKeyRepository
+}
+
+// NewAPIKeyAuthenticator create API key authenticator on top of repository
+func NewAPIKeyAuthenticator(repo repository.APIKeyRepository) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{repo: repo}
+}
+
+// Authenticate get principal of API key, ErrInvalidCredentials is returned
+// when key is unknown, revoked or expired
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	prefix, ok := parseAPIKey(key)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	stored := &models.APIKeyDBModel{Prefix: prefix}
+	if err := a.repo.FindByPrefix(ctx, stored); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(stored.KeyHash, HashAPIKey(key)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	if stored.RevokedAt != nil || (stored.ExpiresAt != nil && stored.ExpiresAt.Before(time.Now())) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// last use is only recorded once a minute, so busy key does not cost a
+	// write on every request
+	if stored.LastUsedAt == nil || time.Since(*stored.LastUsedAt) >= touchInterval {
+		if err := a.repo.Touch(ctx, stored); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Principal{Subject: stored.Name, Method: MethodAPIKey, Roles: stored.Roles}, nil
+}
@@ -0,0 +1,197 @@
+// Package auth API key and JWT bearer token authentication
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// JWTConfig keys and expected claims of accepted JWT
+type JWTConfig struct {
+	// HMACSecret HS256 shared secret, HS256 is rejected when empty
+	HMACSecret []byte
+	// RSAKeys RS256 public keys by key ID, key with empty ID is used for
+	// token without kid header. RS256 is rejected when empty.
+	RSAKeys map[string]*rsa.PublicKey
+	// Issuer and Audience are checked when set
+	Issuer   string
+	Audience string
+	// Leeway allowed clock skew on expiry and not before claims
+	Leeway time.Duration
+}
+
+// JWTClaims accepted JWT claims
+type JWTClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// JWTVerifier verify HS256 and RS256 signed bearer tokens
+type JWTVerifier struct {
+	config JWTConfig
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier create JWT verifier, nil is returned when config has no key
+func NewJWTVerifier(config JWTConfig) *JWTVerifier {
+	var methods []string
+	if len(config.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(config.RSAKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &JWTVerifier{config: config, parser: jwt.NewParser(options...)}
+}
+
+// NewJWTVerifierFromEnv create JWT verifier from AUTH_JWT_* env, nil is
+// returned when no key is configured
+func NewJWTVerifierFromEnv() (*JWTVerifier, error) {
+	config := JWTConfig{
+		HMACSecret: []byte(os.Getenv("AUTH_JWT_HS256_SECRET")),
+		RSAKeys:    map[string]*rsa.PublicKey{},
+		Issuer:     os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+		Leeway:     utilities.EnvDuration("AUTH_JWT_LEEWAY", 0),
+	}
+
+	if path := os.Getenv("AUTH_JWT_RS256_PUBLIC_KEY"); path != "" {
+		key, err := LoadRSAPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		config.RSAKeys[""] = key
+	}
+
+	if path := os.Getenv("AUTH_JWT_JWKS_FILE"); path != "" {
+		keys, err := LoadJWKS(path)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			config.RSAKeys[kid] = key
+		}
+	}
+
+	return NewJWTVerifier(config), nil
+}
+
+// Authenticate get principal of signed token, ErrInvalidCredentials is
+// returned when token is malformed, expired, signed by unknown key or its
+// subject is missing or longer than MaxSubjectLength
+func (v *JWTVerifier) Authenticate(token string) (*Principal, error) {
+	claims := new(JWTClaims)
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	if len(claims.Subject) > MaxSubjectLength {
+		return nil, fmt.Errorf("%w: token subject is too long", ErrInvalidCredentials)
+	}
+
+	return &Principal{Subject: claims.Subject, Method: MethodJWT, Roles: claims.Roles}, nil
+}
+
+// key resolve verification key of token by its signing method and kid
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.config.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.config.RSAKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	default:
+		return nil, errors.New("unexpected signing method")
+	}
+}
+
+// LoadRSAPublicKey read PEM encoded RSA public key file
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.ParseRSAPublicKeyFromPEM(data)
+}
+
+// jwk single JSON Web Key, only RSA keys are used
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// LoadJWKS read RSA signing keys of JWKS file by their key ID, other keys
+// are skipped
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS file: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent of key %q", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
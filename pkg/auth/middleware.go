@@ -0,0 +1,130 @@
+// Package auth API key and JWT bearer token authentication
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/audit"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// APIKeyHeader header carrying API key, API key may be sent as bearer token
+// as well
+const APIKeyHeader = "X-API-Key"
+
+// Auth authenticate request by API key or JWT bearer token and authorize it
+// by principal roles
+type Auth struct {
+	// AnonymousRead let anonymous request through reader routes
+	AnonymousRead bool
+
+	apiKeys *APIKeyAuthenticator
+	jwt     *JWTVerifier
+}
+
+// New create authenticator, JWT bearer tokens are rejected when jwt is nil
+func New(apiKeys repository.APIKeyRepository, jwt *JWTVerifier) *Auth {
+	return &Auth{
+		AnonymousRead: utilities.EnvBool("AUTH_ANONYMOUS_READ", true),
+		apiKeys:       NewAPIKeyAuthenticator(apiKeys),
+		jwt:           jwt,
+	}
+}
+
+// Middleware authenticate request credentials and attach its principal to
+// gin context and request context actor. Request without credentials stay
+// anonymous, invalid credentials are rejected right away.
+func (a *Auth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential, isAPIKey := credentials(c)
+		if credential == "" {
+			c.Next()
+			return
+		}
+
+		var principal *Principal
+		var err error
+		switch {
+		case isAPIKey:
+			principal, err = a.apiKeys.Authenticate(c.Request.Context(), credential)
+		case a.jwt != nil:
+			principal, err = a.jwt.Authenticate(credential)
+		default:
+			err = ErrInvalidCredentials
+		}
+
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCredentials) {
+				log.Println(err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+				return
+			}
+
+			unauthorized(c, "invalid credentials")
+			return
+		}
+
+		SetPrincipal(c, principal)
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), principal.Actor()))
+		c.Next()
+	}
+}
+
+// Allow authorize principal granted role or a higher one, anonymous request
+// is only let through reader routes when AnonymousRead is set
+func (a *Auth) Allow(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			if role == RoleReader && a.AnonymousRead {
+				c.Next()
+				return
+			}
+
+			unauthorized(c, "authentication required")
+			return
+		}
+
+		if !principal.Can(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"msg": role + " role required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasCredentials tells whether request carry API key or bearer token, valid
+// or not
+func HasCredentials(c *gin.Context) bool {
+	credential, _ := credentials(c)
+	return credential != ""
+}
+
+// credentials get credential from X-API-Key or bearer Authorization header,
+// and whether it is an API key
+func credentials(c *gin.Context) (string, bool) {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key, true
+	}
+
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// unauthorized abort request with 401 and bearer challenge
+func unauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", `Bearer realm="bookies"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"msg": msg})
+}
@@ -0,0 +1,86 @@
+// Package auth API key and JWT bearer token authentication
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Roles, every role is granted what lower roles are
+const (
+	RoleReader = "reader"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// roleLevel order of roles, unknown role grants nothing
+var roleLevel = map[string]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ValidRole tells whether role is known
+func ValidRole(role string) bool {
+	_, ok := roleLevel[role]
+	return ok
+}
+
+// MaxSubjectLength longest accepted subject, so actor of any method fits
+// audit_events.actor varchar(128)
+const MaxSubjectLength = 128 - len(MethodAPIKey+":")
+
+// principalKey gin context key holding authenticated principal
+const principalKey = "auth.principal"
+
+// Principal authenticated caller of a request
+type Principal struct {
+	// Subject API key name or JWT subject
+	Subject string `json:"subject"`
+	// Method authentication method, MethodAPIKey or MethodJWT
+	Method string   `json:"method"`
+	Roles  []string `json:"roles"`
+}
+
+// Actor principal identity recorded on audit events
+func (p *Principal) Actor() string {
+	return p.Method + ":" + p.Subject
+}
+
+// Can tells whether principal is granted role or a higher one
+func (p *Principal) Can(role string) bool {
+	required, ok := roleLevel[role]
+	if !ok {
+		return false
+	}
+
+	for _, granted := range p.Roles {
+		if roleLevel[granted] >= required {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetPrincipal attach principal to gin context
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// PrincipalFrom get principal attached to gin context, false when request
+// is anonymous
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	p, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+
+	principal, ok := p.(*Principal)
+	return principal, ok
+}
@@ -0,0 +1,122 @@
+// Package covers Book cover image processing
+package covers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strconv"
+
+	// decoders of accepted formats
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ContentTypes accepted cover image types, detected from content
+var ContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// MaxPixels largest accepted image area, bounds memory used to decode it
+const MaxPixels = 25_000_000
+
+// thumbnailQuality JPEG quality of thumbnails
+const thumbnailQuality = 85
+
+// ErrUnsupportedType tells that content is not one of ContentTypes
+var ErrUnsupportedType = errors.New("cover must be JPEG, PNG, GIF or WebP image")
+
+// ErrTooManyPixels tells that image is larger than MaxPixels
+var ErrTooManyPixels = errors.New("cover image dimensions are too large")
+
+// ErrInvalidImage tells that content is not a readable image of its type
+var ErrInvalidImage = errors.New("cover image is damaged or truncated")
+
+// Cover uploaded image along with its thumbnail
+type Cover struct {
+	// Data original image as uploaded
+	Data        []byte
+	ContentType string
+	// Extension of original image type including dot, such as ".png"
+	Extension string
+	Width     int
+	Height    int
+	// Thumbnail JPEG image scaled down to thumbnail width
+	Thumbnail []byte
+	// Hash hex SHA-256 of original image
+	Hash string
+}
+
+// Process detect type of data from its content regardless of declared one,
+// check its dimensions and create thumbnail at most width pixels wide
+func Process(data []byte, width int) (*Cover, error) {
+	detected := mimetype.Detect(data)
+	if !mimetype.EqualsAny(detected.String(), ContentTypes...) {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	thumbnail, err := Thumbnail(img, width)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	return &Cover{
+		Data:        data,
+		ContentType: detected.String(),
+		Extension:   detected.Extension(),
+		Width:       config.Width,
+		Height:      config.Height,
+		Thumbnail:   thumbnail,
+		Hash:        hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Thumbnail encode img as JPEG scaled down to at most width pixels wide
+// keeping its aspect ratio, smaller image keep its size. Transparent area
+// become white.
+func Thumbnail(img image.Image, width int) ([]byte, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > width {
+		h = max(1, h*width/w)
+		w = width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Keys storage keys of cover original and thumbnail, derived from book and
+// image content so a new image never reuse URL of the previous one
+func Keys(bookID int, cover *Cover) (original, thumbnail string) {
+	prefix := "covers/" + strconv.Itoa(bookID) + "/" + cover.Hash[:16]
+	return prefix + cover.Extension, prefix + "_thumb.jpg"
+}
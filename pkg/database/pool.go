@@ -25,6 +25,12 @@ func (db *DbPool) Ping(ctx context.Context) error {
 	return db.Conn.Ping(ctx)
 }
 
+// Close close all pool connections, waiting acquired connections to be
+// released
+func (db *DbPool) Close() {
+	db.Conn.Close()
+}
+
 // GetConnection Get database connection pool instance, it's also ensuring that
 // we only create the connection once
 func GetConnection(ctx context.Context) (*DbPool, error) {
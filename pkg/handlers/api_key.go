@@ -0,0 +1,98 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// APIKeyHandler Controllers for API keys
+type APIKeyHandler struct {
+	APIKeyRepo repository.APIKeyRepository
+}
+
+// Fetch get list of API keys
+func (ac *APIKeyHandler) Fetch(c *gin.Context) {
+	keys, err := ac.APIKeyRepo.List(c.Request.Context())
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// Add create new API key, the key itself is only shown in this response
+func (ac *APIKeyHandler) Add(c *gin.Context) {
+	var reqBody models.APIKeyBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	if reqBody.ExpiresAt != nil && reqBody.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "expires_at must be in the future"})
+		return
+	}
+
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	key := &models.CreatedAPIKey{
+		APIKeyDBModel: models.APIKeyDBModel{
+			Name:      reqBody.Name,
+			Prefix:    prefix,
+			KeyHash:   hash,
+			Roles:     reqBody.Roles,
+			ExpiresAt: reqBody.ExpiresAt,
+		},
+		Key: secret,
+	}
+
+	if err := ac.APIKeyRepo.Insert(c.Request.Context(), &key.APIKeyDBModel); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// Revoke revoke API key by its prefix
+func (ac *APIKeyHandler) Revoke(c *gin.Context) {
+	var prefixURI models.APIKeyPrefixURI
+	if err := c.ShouldBindUri(&prefixURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+			return
+		}
+	}
+
+	key := &models.APIKeyDBModel{Prefix: prefixURI.Prefix}
+	if err := ac.APIKeyRepo.Revoke(c.Request.Context(), key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "api key not found or already revoked"})
+		} else {
+			internalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "API Key Revoked"})
+}
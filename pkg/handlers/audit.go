@@ -0,0 +1,95 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// AuditHandler Controllers for audit events
+type AuditHandler struct {
+	AuditRepo repository.AuditRepository
+}
+
+// Fetch get list of audit events filtered by query params
+func (ac *AuditHandler) Fetch(c *gin.Context) {
+	var filter models.AuditFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+
+	ac.list(c, &filter)
+}
+
+// AuthorHistory get audit events of single author
+func (ac *AuditHandler) AuthorHistory(c *gin.Context) {
+	ac.history(c, "author")
+}
+
+// BookHistory get audit events of single book
+func (ac *AuditHandler) BookHistory(c *gin.Context) {
+	ac.history(c, "book")
+}
+
+// PublisherHistory get audit events of single publisher
+func (ac *AuditHandler) PublisherHistory(c *gin.Context) {
+	ac.history(c, "publisher")
+}
+
+// SeriesHistory get audit events of single series
+func (ac *AuditHandler) SeriesHistory(c *gin.Context) {
+	ac.history(c, "series")
+}
+
+// GenreHistory get audit events of single genre
+func (ac *AuditHandler) GenreHistory(c *gin.Context) {
+	ac.history(c, "genre")
+}
+
+// history write audit events of entity identified by URI
+func (ac *AuditHandler) history(c *gin.Context, entity string) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return
+		}
+	}
+
+	var filter models.AuditFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+
+	id, _ := strconv.Atoi(idURI.ID)
+	filter.Entity = entity
+	filter.ID = &id
+
+	ac.list(c, &filter)
+}
+
+// list write page of audit events matching filter
+func (ac *AuditHandler) list(c *gin.Context, filter *models.AuditFilter) {
+	var page models.Pagination
+	if !bindPagination(c, &page) {
+		return
+	}
+
+	events := new(models.FetchAuditDBModel)
+	events.Pagination = page
+
+	if err := ac.AuditRepo.Fetch(c.Request.Context(), events, filter); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
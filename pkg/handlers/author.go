@@ -3,7 +3,6 @@ package handlers
 
 import (
 	"errors"
-	"log"
 	"net/http"
 	"strconv"
 
@@ -13,34 +12,32 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
 	"github.com/kasfil/bookies/pkg/utilities"
 )
 
 // AuthorHandler Controllers for author
-type AuthorHandler struct{}
+type AuthorHandler struct {
+	AuthorRepo repository.AuthorRepository
+	BookRepo   repository.BookRepository
+}
 
 // Add insert new author record
 func (ac *AuthorHandler) Add(c *gin.Context) {
 	var authorBody models.AuthorBaseModel
 	if err := c.ShouldBind(&authorBody); err != nil {
-		msg := utilities.ParseValidationError(err.(validator.ValidationErrors))
-		c.JSON(http.StatusUnprocessableEntity, msg)
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
 		return
 	}
 
 	author := new(models.AuthorDBModel)
-	err := author.Insert(&authorBody)
+	err := ac.AuthorRepo.Insert(c.Request.Context(), author, &authorBody)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			switch pgErr.Code {
-			case "23505":
-				c.JSON(http.StatusConflict, gin.H{"msg": "email already registered"})
-			}
-		} else {
-			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops we made mistake"})
-		}
+		writeAuthorError(c, err)
 		return
 	}
 
@@ -49,28 +46,40 @@ func (ac *AuthorHandler) Add(c *gin.Context) {
 
 // Fetch get list of authors from database
 func (ac *AuthorHandler) Fetch(c *gin.Context) {
-	// Get page value from query params
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil || page < 1 {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "page parameter should be number and greater than 1"})
+	ac.list(c, false)
+}
+
+// Trash get list of deleted authors
+func (ac *AuthorHandler) Trash(c *gin.Context) {
+	ac.list(c, true)
+}
+
+// list write page of authors, trashed list deleted authors only
+func (ac *AuthorHandler) list(c *gin.Context, trashed bool) {
+	var page models.Pagination
+	if !bindPagination(c, &page) {
+		return
+	}
+
+	var filter models.AuthorFilter
+	if !bindQuery(c, &filter) {
 		return
 	}
+	filter.IncludeDeleted = includeDeleted(c)
+	filter.Trashed = trashed
 
-	// Get limit value from query params
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil || limit < 5 || limit > 100 {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "limit parameter should be number and between 5 and 100"})
+	sortKeys, err := models.ParseSort(filter.Sort, models.AuthorSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
 		return
 	}
+	filter.SortKeys = sortKeys
 
 	authors := new(models.FetchAuthorDBModel)
-	authors.Page = page
-	authors.Limit = limit
+	authors.Pagination = page
 
-	err = authors.Fetch()
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	if err := ac.AuthorRepo.Fetch(c.Request.Context(), authors, &filter); err != nil {
+		internalError(c, err)
 		return
 	}
 
@@ -91,15 +100,35 @@ func (ac *AuthorHandler) Get(c *gin.Context) {
 	author := new(models.AuthorDBModel)
 	author.ID, _ = strconv.Atoi(authorDetail.ID)
 
-	if err := author.Detail(); err != nil {
+	// answer conditional request from version and modification time only
+	if conditional(c) {
+		if err := ac.AuthorRepo.Stamp(c.Request.Context(), author); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"msg": "author not found"})
+			} else {
+				internalError(c, err)
+			}
+			return
+		}
+
+		tag := etag(author.Version, author.UpdatedAt)
+		if notModified(c, tag, author.UpdatedAt) {
+			setValidators(c, tag, author.UpdatedAt)
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	if err := ac.AuthorRepo.Detail(c.Request.Context(), author); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"msg": "author not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+			internalError(c, err)
 		}
 		return
 	}
 
+	setValidators(c, etag(author.Version, author.UpdatedAt), author.UpdatedAt)
 	c.JSON(http.StatusOK, author)
 }
 
@@ -108,14 +137,58 @@ func (ac *AuthorHandler) Update(c *gin.Context) {
 	var authorDetail models.IdentifierURI
 	if err := c.ShouldBindUri(&authorDetail); err != nil {
 		if validatorErr, ok := err.(validator.ValidationErrors); ok {
-			msgs := utilities.ParseValidationError(validatorErr)
-			c.JSON(http.StatusUnprocessableEntity, msgs)
-			return
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
 		}
+		return
 	}
 
 	var reqBody models.AuthorBaseModel
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	author := new(models.AuthorDBModel)
+	author.ID, _ = strconv.Atoi(authorDetail.ID)
+
+	if err := ac.AuthorRepo.Detail(c.Request.Context(), author); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "author not found"})
+		} else {
+			internalError(c, err)
+		}
+		return
+	}
+
+	if !ifMatch(c, etag(author.Version, author.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	if err := ac.AuthorRepo.Update(c.Request.Context(), author, &reqBody); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writeAuthorError(c, err)
+		return
+	}
+
+	setValidators(c, etag(author.Version, author.UpdatedAt), author.UpdatedAt)
+	c.JSON(http.StatusOK, author)
+}
+
+// Patch partially update author with JSON Merge Patch or JSON Patch
+// document, only changed fields are written
+func (ac *AuthorHandler) Patch(c *gin.Context) {
+	var authorDetail models.IdentifierURI
+	if err := c.ShouldBindUri(&authorDetail); err != nil {
 		if validatorErr, ok := err.(validator.ValidationErrors); ok {
 			msgs := utilities.ParseValidationError(validatorErr)
 			c.JSON(http.StatusUnprocessableEntity, msgs)
@@ -126,21 +199,36 @@ func (ac *AuthorHandler) Update(c *gin.Context) {
 	author := new(models.AuthorDBModel)
 	author.ID, _ = strconv.Atoi(authorDetail.ID)
 
-	if err := author.Detail(); err != nil {
+	if err := ac.AuthorRepo.Detail(c.Request.Context(), author); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"msg": "author not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+			internalError(c, err)
 		}
 		return
 	}
 
-	if err := author.Update(&reqBody); err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	if !ifMatch(c, etag(author.Version, author.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	var reqBody models.AuthorBaseModel
+	fields, ok := applyPatch(c, author.Base(), &reqBody)
+	if !ok {
+		return
+	}
+
+	if err := ac.AuthorRepo.Patch(c.Request.Context(), author, &reqBody, fields); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writeAuthorError(c, err)
 		return
 	}
 
+	setValidators(c, etag(author.Version, author.UpdatedAt), author.UpdatedAt)
 	c.JSON(http.StatusOK, author)
 }
 
@@ -158,24 +246,49 @@ func (ac *AuthorHandler) Delete(c *gin.Context) {
 	author := new(models.AuthorDBModel)
 	author.ID, _ = strconv.Atoi(authorDetail.ID)
 
-	if err := author.Detail(); err != nil {
+	if err := ac.AuthorRepo.Detail(c.Request.Context(), author); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"msg": "author not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+			internalError(c, err)
 		}
 		return
 	}
 
-	if err := author.Delete(); err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	if err := ac.AuthorRepo.Delete(c.Request.Context(), author); err != nil {
+		internalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"msg": "Author Removed"})
 }
 
+// Restore bring back deleted author by ID handler
+func (ac *AuthorHandler) Restore(c *gin.Context) {
+	var authorDetail models.IdentifierURI
+	if err := c.ShouldBindUri(&authorDetail); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return
+		}
+	}
+
+	author := new(models.AuthorDBModel)
+	author.ID, _ = strconv.Atoi(authorDetail.ID)
+
+	if err := ac.AuthorRepo.Restore(c.Request.Context(), author); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "author not found in trash"})
+		} else {
+			internalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, author)
+}
+
 // Books get author books
 func (ac *AuthorHandler) Books(c *gin.Context) {
 	var authorDetail models.IdentifierURI
@@ -187,29 +300,55 @@ func (ac *AuthorHandler) Books(c *gin.Context) {
 		}
 	}
 
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil || page < 1 {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "page parameter should be number and greater than 1"})
+	var page models.Pagination
+	if !bindPagination(c, &page) {
 		return
 	}
 
-	// Get limit value from query params
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil || limit < 5 || limit > 100 {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "limit parameter should be number and between 5 and 100"})
+	var filter models.BookFilter
+	if !bindQuery(c, &filter) {
 		return
 	}
+	filter.IncludeDeleted = includeDeleted(c)
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.BookSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	filter.SortKeys = sortKeys
 
 	authorID, _ := strconv.Atoi(authorDetail.ID)
+	filter.AuthorID = &authorID
+
 	books := new(models.FetchBookDBModel)
-	books.Page = page
-	books.Limit = limit
+	books.Pagination = page
 
-	if err := books.Fetch(&authorID); err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	if err := ac.BookRepo.Fetch(c.Request.Context(), books, &filter); err != nil {
+		internalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, books)
 }
+
+// writeAuthorError write response for author insert or update error
+func writeAuthorError(c *gin.Context, err error) {
+	if status, msg, ok := authorErrorStatus(err); ok {
+		c.JSON(status, gin.H{"msg": msg})
+		return
+	}
+
+	internalError(c, err)
+}
+
+// authorErrorStatus response status and message of author write error caused
+// by request data, false when err is not one of them
+func authorErrorStatus(err error) (int, string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return http.StatusConflict, "email already registered", true
+	}
+
+	return 0, "", false
+}
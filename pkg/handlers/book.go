@@ -3,8 +3,6 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 
@@ -14,34 +12,32 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
 	"github.com/kasfil/bookies/pkg/utilities"
+	"github.com/kasfil/bookies/pkg/validators"
 )
 
 // BookHandler Controllers for author
-type BookHandler struct{}
+type BookHandler struct {
+	BookRepo repository.BookRepository
+}
 
 // Add insert new book record
 func (ac *BookHandler) Add(c *gin.Context) {
 	var reqBody models.BookBaseModel
 	if err := c.ShouldBind(&reqBody); err != nil {
-		msg := utilities.ParseValidationError(err.(validator.ValidationErrors))
-		c.JSON(http.StatusUnprocessableEntity, msg)
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
 		return
 	}
 
 	book := new(models.BookDBModel)
-	err := book.Insert(&reqBody)
+	err := ac.BookRepo.Insert(c.Request.Context(), book, &reqBody)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			switch pgErr.Code {
-			case "23503":
-				c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "unknown author"})
-			}
-		} else {
-			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops we made mistake"})
-		}
+		writeBookError(c, err)
 		return
 	}
 
@@ -50,28 +46,83 @@ func (ac *BookHandler) Add(c *gin.Context) {
 
 // Fetch get list of book records from database
 func (ac *BookHandler) Fetch(c *gin.Context) {
-	// Get page value from query params
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil || page < 1 {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "page parameter should be number and greater than 1"})
+	ac.list(c, false)
+}
+
+// Trash get list of deleted books
+func (ac *BookHandler) Trash(c *gin.Context) {
+	ac.list(c, true)
+}
+
+// list write page of books, trashed list deleted books only
+func (ac *BookHandler) list(c *gin.Context, trashed bool) {
+	var page models.Pagination
+	if !bindPagination(c, &page) {
 		return
 	}
 
-	// Get limit value from query params
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil || limit < 5 || limit > 100 {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "limit parameter should be number and between 5 and 100"})
+	var filter models.BookFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+	filter.IncludeDeleted = includeDeleted(c)
+	filter.Trashed = trashed
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.BookSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
 		return
 	}
+	filter.SortKeys = sortKeys
 
 	books := new(models.FetchBookDBModel)
-	books.Page = page
-	books.Limit = limit
+	books.Pagination = page
 
-	err = books.Fetch(nil)
+	if err := ac.BookRepo.Fetch(c.Request.Context(), books, &filter); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, books)
+}
+
+// Editions get books which are editions of work by ID
+func (ac *BookHandler) Editions(c *gin.Context) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return
+		}
+	}
+
+	var page models.Pagination
+	if !bindPagination(c, &page) {
+		return
+	}
+
+	var filter models.BookFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+	filter.IncludeDeleted = includeDeleted(c)
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.BookSortFields)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	filter.SortKeys = sortKeys
+
+	workID, _ := strconv.Atoi(idURI.ID)
+	filter.WorkID = &workID
+
+	books := new(models.FetchBookDBModel)
+	books.Pagination = page
+
+	if err := ac.BookRepo.Fetch(c.Request.Context(), books, &filter); err != nil {
+		internalError(c, err)
 		return
 	}
 
@@ -92,15 +143,68 @@ func (ac *BookHandler) Get(c *gin.Context) {
 	book := new(models.BookDBModel)
 	book.ID, _ = strconv.Atoi(idURI.ID)
 
-	if err := book.Detail(); err != nil {
+	// answer conditional request from version and modification time only
+	if conditional(c) {
+		if err := ac.BookRepo.Stamp(c.Request.Context(), book); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
+			} else {
+				internalError(c, err)
+			}
+			return
+		}
+
+		tag := etag(book.Version, book.UpdatedAt)
+		if notModified(c, tag, book.UpdatedAt) {
+			setValidators(c, tag, book.UpdatedAt)
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	if err := ac.BookRepo.Detail(c.Request.Context(), book); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+			internalError(c, err)
 		}
 		return
 	}
 
+	setValidators(c, etag(book.Version, book.UpdatedAt), book.UpdatedAt)
+	c.JSON(http.StatusOK, book)
+}
+
+// ByISBN get detail book by ISBN-10 or ISBN-13
+func (ac *BookHandler) ByISBN(c *gin.Context) {
+	var isbnURI models.ISBNURI
+	if err := c.ShouldBindUri(&isbnURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return
+		}
+	}
+
+	isbn, _ := validators.NormalizeISBN(isbnURI.ISBN)
+	books, err := ac.BookRepo.FindByISBN(c.Request.Context(), []string{isbn})
+	if err != nil {
+		internalError(c, err)
+		return
+	} else if len(books) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
+		return
+	}
+
+	book := &books[0]
+	tag := etag(book.Version, book.UpdatedAt)
+	if notModified(c, tag, book.UpdatedAt) {
+		setValidators(c, tag, book.UpdatedAt)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	setValidators(c, tag, book.UpdatedAt)
 	c.JSON(http.StatusOK, book)
 }
 
@@ -109,14 +213,58 @@ func (ac *BookHandler) Update(c *gin.Context) {
 	var idURI models.IdentifierURI
 	if err := c.ShouldBindUri(&idURI); err != nil {
 		if validatorErr, ok := err.(validator.ValidationErrors); ok {
-			msgs := utilities.ParseValidationError(validatorErr)
-			c.JSON(http.StatusUnprocessableEntity, msgs)
-			return
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
 		}
+		return
 	}
 
 	var reqBody models.BookBaseModel
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	book := new(models.BookDBModel)
+	book.ID, _ = strconv.Atoi(idURI.ID)
+
+	if err := ac.BookRepo.Detail(c.Request.Context(), book); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
+		} else {
+			internalError(c, err)
+		}
+		return
+	}
+
+	if !ifMatch(c, etag(book.Version, book.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	if err := ac.BookRepo.Update(c.Request.Context(), book, &reqBody); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writeBookError(c, err)
+		return
+	}
+
+	setValidators(c, etag(book.Version, book.UpdatedAt), book.UpdatedAt)
+	c.JSON(http.StatusOK, book)
+}
+
+// Patch partially update book with JSON Merge Patch or JSON Patch document,
+// only changed fields are written
+func (ac *BookHandler) Patch(c *gin.Context) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
 		if validatorErr, ok := err.(validator.ValidationErrors); ok {
 			msgs := utilities.ParseValidationError(validatorErr)
 			c.JSON(http.StatusUnprocessableEntity, msgs)
@@ -127,22 +275,36 @@ func (ac *BookHandler) Update(c *gin.Context) {
 	book := new(models.BookDBModel)
 	book.ID, _ = strconv.Atoi(idURI.ID)
 
-	if err := book.Detail(); err != nil {
+	if err := ac.BookRepo.Detail(c.Request.Context(), book); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
 		} else {
-			fmt.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+			internalError(c, err)
 		}
 		return
 	}
 
-	if err := book.Update(&reqBody); err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	if !ifMatch(c, etag(book.Version, book.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	var reqBody models.BookBaseModel
+	fields, ok := applyPatch(c, book.Base(), &reqBody)
+	if !ok {
+		return
+	}
+
+	if err := ac.BookRepo.Patch(c.Request.Context(), book, &reqBody, fields); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writeBookError(c, err)
 		return
 	}
 
+	setValidators(c, etag(book.Version, book.UpdatedAt), book.UpdatedAt)
 	c.JSON(http.StatusOK, book)
 }
 
@@ -160,20 +322,114 @@ func (ac *BookHandler) Delete(c *gin.Context) {
 	author := new(models.BookDBModel)
 	author.ID, _ = strconv.Atoi(idURI.ID)
 
-	if err := author.Detail(); err != nil {
+	if err := ac.BookRepo.Detail(c.Request.Context(), author); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+			internalError(c, err)
 		}
 		return
 	}
 
-	if err := author.Delete(); err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	if err := ac.BookRepo.Delete(c.Request.Context(), author); err != nil {
+		internalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"msg": "Book Removed"})
 }
+
+// Restore bring back deleted book by ID handler
+func (ac *BookHandler) Restore(c *gin.Context) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return
+		}
+	}
+
+	book := new(models.BookDBModel)
+	book.ID, _ = strconv.Atoi(idURI.ID)
+
+	if err := ac.BookRepo.Restore(c.Request.Context(), book); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"msg": "book not found in trash"})
+		case errors.Is(err, repository.ErrAuthorDeleted):
+			c.JSON(http.StatusConflict, gin.H{"msg": "restore the book author first"})
+		case isISBNConflict(err):
+			c.JSON(http.StatusConflict, gin.H{"msg": "another book has the same isbn"})
+		case isSeriesPositionConflict(err):
+			c.JSON(http.StatusConflict, gin.H{"msg": "another book has the same position in series"})
+		default:
+			internalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, book)
+}
+
+// writeBookError write response for book insert or update error
+func writeBookError(c *gin.Context, err error) {
+	if status, msg, ok := bookErrorStatus(err); ok {
+		c.JSON(status, gin.H{"msg": msg})
+		return
+	}
+
+	internalError(c, err)
+}
+
+// bookErrorStatus response status and message of book write error caused by
+// request data, false when err is not one of them
+func bookErrorStatus(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, repository.ErrUnknownGenre):
+		return http.StatusUnprocessableEntity, err.Error(), true
+	case errors.Is(err, repository.ErrAuthorDeleted):
+		return http.StatusUnprocessableEntity, "author is deleted, restore it first", true
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return 0, "", false
+	}
+
+	switch {
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "book_genres_genre_id_fkey":
+		// genre removed while book was written
+		return http.StatusUnprocessableEntity, "unknown genre", true
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "books_publisher_id_fkey":
+		return http.StatusUnprocessableEntity, "unknown publisher", true
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "books_work_id_fkey":
+		return http.StatusUnprocessableEntity, "unknown work", true
+	case pgErr.Code == "23503" && pgErr.ConstraintName == "books_series_id_fkey":
+		return http.StatusUnprocessableEntity, "unknown series", true
+	case pgErr.Code == "23503":
+		return http.StatusUnprocessableEntity, "unknown author", true
+	case pgErr.Code == "23505" && pgErr.ConstraintName == "book_contributors_pkey":
+		return http.StatusUnprocessableEntity, "duplicate contributor with same role", true
+	case isISBNConflict(err):
+		return http.StatusConflict, "isbn already registered", true
+	case isSeriesPositionConflict(err):
+		return http.StatusConflict, "position already taken in series", true
+	}
+
+	return 0, "", false
+}
+
+// isISBNConflict tells whether err is caused by another active book having
+// the same ISBN
+func isISBNConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "books_isbn_key"
+}
+
+// isSeriesPositionConflict tells whether err is caused by another active book
+// having the same position in the same series
+func isSeriesPositionConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "books_series_position_key"
+}
@@ -0,0 +1,267 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// bulkEntity entity specific parts of bulk write
+type bulkEntity[M, D any] struct {
+	// name used in not found message
+	name string
+	// run write operations in a single transaction
+	run func(ctx context.Context, ops []repository.BulkOp[M, D], atomic bool) ([]error, error)
+	// model record with ID and version of the operation
+	model func(id, version int) *M
+	// stamp ID and version of written record
+	stamp func(m *M) (id, version int)
+	// status response status and message of entity specific errors, false
+	// when err is not one of them
+	status func(err error) (int, string, bool)
+}
+
+// Bulk create or update many authors at once
+func (ac *AuthorHandler) Bulk(c *gin.Context) {
+	bulk(c, authorBulk(ac.AuthorRepo), repository.BulkCreate, repository.BulkUpdate)
+}
+
+// BulkDelete delete many authors at once
+func (ac *AuthorHandler) BulkDelete(c *gin.Context) {
+	bulk(c, authorBulk(ac.AuthorRepo), repository.BulkDelete)
+}
+
+// Bulk create or update many books at once
+func (ac *BookHandler) Bulk(c *gin.Context) {
+	bulk(c, bookBulk(ac.BookRepo), repository.BulkCreate, repository.BulkUpdate)
+}
+
+// BulkDelete delete many books at once
+func (ac *BookHandler) BulkDelete(c *gin.Context) {
+	bulk(c, bookBulk(ac.BookRepo), repository.BulkDelete)
+}
+
+// authorBulk author parts of bulk write
+func authorBulk(repo repository.AuthorRepository) bulkEntity[models.AuthorDBModel, models.AuthorBaseModel] {
+	return bulkEntity[models.AuthorDBModel, models.AuthorBaseModel]{
+		name: "author",
+		run:  repo.Bulk,
+		model: func(id, version int) *models.AuthorDBModel {
+			return &models.AuthorDBModel{ID: id, Version: version}
+		},
+		stamp: func(m *models.AuthorDBModel) (int, int) {
+			return m.ID, m.Version
+		},
+		status: authorErrorStatus,
+	}
+}
+
+// bookBulk book parts of bulk write
+func bookBulk(repo repository.BookRepository) bulkEntity[models.BookDBModel, models.BookBaseModel] {
+	return bulkEntity[models.BookDBModel, models.BookBaseModel]{
+		name: "book",
+		run:  repo.Bulk,
+		model: func(id, version int) *models.BookDBModel {
+			return &models.BookDBModel{ID: id, Version: version}
+		},
+		stamp: func(m *models.BookDBModel) (int, int) {
+			return m.ID, m.Version
+		},
+		status: bookErrorStatus,
+	}
+}
+
+// bulk validate every item of bulk request and write the valid ones, the
+// response list outcome of each item in request order. All-or-nothing mode
+// write nothing when any item is invalid or fails. Only given actions are
+// accepted, item without op defaults to the first one.
+func bulk[M, D any](c *gin.Context, e bulkEntity[M, D], actions ...string) {
+	var reqBody models.BulkRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	if reqBody.Mode == "" {
+		reqBody.Mode = models.BulkAtomic
+	}
+	atomic := reqBody.Mode == models.BulkAtomic
+
+	results := make([]models.BulkResult, len(reqBody.Items))
+	var ops []repository.BulkOp[M, D]
+	var opResults []*models.BulkResult
+	for i, item := range reqBody.Items {
+		results[i].Index = i
+
+		op := repository.BulkOp[M, D]{Action: item.Op, Model: e.model(item.ID, item.Version)}
+		if op.Action == "" {
+			op.Action = actions[0]
+		}
+
+		// each action is allowed by the role of its own route
+		if !slices.Contains(actions, op.Action) {
+			results[i].Status, results[i].Msg = http.StatusUnprocessableEntity, "op should be "+strings.Join(actions, " or ")+" on this route"
+			continue
+		}
+
+		if op.Action != repository.BulkCreate && item.ID == 0 {
+			results[i].Status, results[i].Msg = http.StatusUnprocessableEntity, "id is required to "+op.Action
+			continue
+		}
+
+		if op.Action != repository.BulkDelete {
+			op.Data = new(D)
+			if !bindItem(&results[i], item.Data, op.Data) {
+				continue
+			}
+		}
+
+		ops = append(ops, op)
+		opResults = append(opResults, &results[i])
+	}
+
+	if !writeOps(c, e, ops, opResults, atomic, len(ops) < len(results)) {
+		return
+	}
+
+	writeBulk(c, reqBody.Mode, results)
+}
+
+// writeOps write valid ops and fill their results, invalid tells that some
+// items were already rejected so all-or-nothing write nothing. Response is
+// written and false returned when the write itself fails.
+func writeOps[M, D any](c *gin.Context, e bulkEntity[M, D], ops []repository.BulkOp[M, D], results []*models.BulkResult, atomic, invalid bool) bool {
+	if atomic && invalid {
+		// valid items only failed because of the others
+		for _, result := range results {
+			result.Status, result.Msg = http.StatusFailedDependency, repository.ErrBulkAborted.Error()
+		}
+		return true
+	}
+
+	if len(ops) == 0 {
+		return true
+	}
+
+	errs, err := e.run(c.Request.Context(), ops, atomic)
+	if err != nil {
+		internalError(c, err)
+		return false
+	}
+
+	for j, err := range errs {
+		result := results[j]
+		if err != nil {
+			result.Status, result.Msg = bulkStatus(e, err)
+			continue
+		}
+
+		result.ID, result.Version = e.stamp(ops[j].Model)
+		switch ops[j].Action {
+		case repository.BulkCreate:
+			result.Status = http.StatusCreated
+		case repository.BulkUpdate:
+			result.Status = http.StatusOK
+		default:
+			result.Status, result.Version = http.StatusNoContent, 0
+		}
+	}
+
+	return true
+}
+
+// bindItem decode and validate bulk item data into obj, result is filled
+// and false returned when data is invalid
+func bindItem(result *models.BulkResult, data json.RawMessage, obj any) bool {
+	if len(data) == 0 {
+		result.Status, result.Msg = http.StatusUnprocessableEntity, "data is required"
+		return false
+	}
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		result.Status, result.Msg = http.StatusUnprocessableEntity, err.Error()
+		return false
+	}
+
+	return validItem(result, obj)
+}
+
+// validItem validate bulk item obj with the same rules as single request,
+// result is filled and false returned when obj is invalid
+func validItem(result *models.BulkResult, obj any) bool {
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		result.Status = http.StatusUnprocessableEntity
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			result.Errors = utilities.ParseValidationError(validatorErr)
+		} else {
+			result.Msg = err.Error()
+		}
+		return false
+	}
+
+	return true
+}
+
+// bulkStatus response status and message of failed bulk operation, the same
+// single request would have got
+func bulkStatus[M, D any](e bulkEntity[M, D], err error) (int, string) {
+	if status, msg, ok := e.status(err); ok {
+		return status, msg
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrBulkAborted):
+		return http.StatusFailedDependency, err.Error()
+	case errors.Is(err, pgx.ErrNoRows):
+		return http.StatusNotFound, e.name + " not found"
+	case errors.Is(err, repository.ErrVersionConflict):
+		return http.StatusPreconditionFailed, "record was modified by someone else, fetch it again"
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		log.Println(err)
+		return http.StatusGatewayTimeout, "request timeout, please try again"
+	default:
+		log.Println(err)
+		return http.StatusInternalServerError, "oops, we made a mistake"
+	}
+}
+
+// writeBulk write bulk response, 200 when every item succeed otherwise 207
+// for best-effort and 422 for all-or-nothing since nothing is written
+func writeBulk(c *gin.Context, mode string, results []models.BulkResult) {
+	response := models.BulkResponse{Mode: mode, Results: results}
+	for _, result := range results {
+		if result.Status < http.StatusBadRequest {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	switch {
+	case response.Failed == 0:
+		c.JSON(http.StatusOK, response)
+	case mode == models.BulkBestEffort:
+		c.JSON(http.StatusMultiStatus, response)
+	default:
+		c.JSON(http.StatusUnprocessableEntity, response)
+	}
+}
@@ -0,0 +1,73 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag strong entity tag of record representation, version change with the
+// record itself and updatedAt with related records shown within it as well
+func etag(version int, updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d-%x"`, version, updatedAt.UnixMicro())
+}
+
+// setValidators write ETag and Last-Modified headers of record
+func setValidators(c *gin.Context, tag string, modified time.Time) {
+	c.Header("ETag", tag)
+	c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+}
+
+// conditional tells whether request carries conditional GET headers
+func conditional(c *gin.Context) bool {
+	return c.GetHeader("If-None-Match") != "" || c.GetHeader("If-Modified-Since") != ""
+}
+
+// notModified tells whether client copy is still fresh. If-None-Match use
+// weak comparison and take precedence over If-Modified-Since.
+func notModified(c *gin.Context, tag string, modified time.Time) bool {
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// Last-Modified has second precision
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// ifMatch tells whether If-Match header match record entity tag, request
+// without the header always match. Weak tags never match.
+func ifMatch(c *gin.Context, tag string) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preconditionFailed write response for update based on stale version
+func preconditionFailed(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, gin.H{"msg": "record was modified by someone else, fetch it again"})
+}
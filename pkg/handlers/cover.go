@@ -0,0 +1,213 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/covers"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/storage"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// coverFormOverhead room for multipart boundaries and headers on top of
+// largest accepted cover
+const coverFormOverhead = 64 << 10
+
+// CoverHandler Controllers for book cover image
+type CoverHandler struct {
+	BookRepo repository.BookRepository
+	Store    storage.Storage
+	// MaxSize largest accepted image in bytes
+	MaxSize int64
+	// ThumbnailWidth width thumbnails are scaled down to
+	ThumbnailWidth int
+}
+
+// Upload replace book cover with image of multipart "cover" field, image
+// type is detected from its content
+func (ac *CoverHandler) Upload(c *gin.Context) {
+	book, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(c, etag(book.Version, book.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	data, ok := ac.readCover(c)
+	if !ok {
+		return
+	}
+
+	cover, err := covers.Process(data, ac.ThumbnailWidth)
+	switch {
+	case errors.Is(err, covers.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"msg": err.Error()})
+		return
+	case errors.Is(err, covers.ErrTooManyPixels) || errors.Is(err, covers.ErrInvalidImage):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	case err != nil:
+		internalError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	objects := new(models.CoverObjects)
+	objects.Key, objects.ThumbnailKey = covers.Keys(book.ID, cover)
+	objects.URL, objects.ThumbnailURL = ac.Store.URL(objects.Key), ac.Store.URL(objects.ThumbnailKey)
+
+	if err := ac.Store.Put(ctx, objects.Key, cover.ContentType, cover.Data); err != nil {
+		internalError(c, err)
+		return
+	}
+	if err := ac.Store.Put(ctx, objects.ThumbnailKey, "image/jpeg", cover.Thumbnail); err != nil {
+		ac.remove(ctx, objects)
+		internalError(c, err)
+		return
+	}
+
+	// same image uploaded again is stored under the same keys
+	unchanged := book.Cover != nil && book.Cover.URL == objects.URL
+	previous, err := ac.BookRepo.SetCover(ctx, book, objects)
+	if err != nil {
+		if !unchanged {
+			ac.remove(ctx, objects)
+		}
+		ac.writeError(c, err)
+		return
+	}
+	if previous != nil && !unchanged {
+		ac.remove(ctx, previous)
+	}
+
+	setValidators(c, etag(book.Version, book.UpdatedAt), book.UpdatedAt)
+	c.JSON(http.StatusOK, book)
+}
+
+// Delete remove book cover
+func (ac *CoverHandler) Delete(c *gin.Context) {
+	book, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if book.Cover == nil {
+		c.JSON(http.StatusNotFound, gin.H{"msg": "book has no cover"})
+		return
+	}
+
+	if !ifMatch(c, etag(book.Version, book.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	previous, err := ac.BookRepo.SetCover(c.Request.Context(), book, nil)
+	if err != nil {
+		ac.writeError(c, err)
+		return
+	}
+	if previous != nil {
+		ac.remove(c.Request.Context(), previous)
+	}
+
+	setValidators(c, etag(book.Version, book.UpdatedAt), book.UpdatedAt)
+	c.JSON(http.StatusOK, book)
+}
+
+// readCover read image of multipart "cover" field, response is written and
+// false returned when it is missing or too large
+func (ac *CoverHandler) readCover(c *gin.Context) ([]byte, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ac.MaxSize+coverFormOverhead)
+	header, err := c.FormFile("cover")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "cover must not be larger than " + strconv.FormatInt(ac.MaxSize, 10) + " bytes"})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "cover file is required as multipart form field cover"})
+		}
+		return nil, false
+	}
+	if header.Size > ac.MaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "cover must not be larger than " + strconv.FormatInt(ac.MaxSize, 10) + " bytes"})
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		internalError(c, err)
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		internalError(c, err)
+		return nil, false
+	}
+
+	return data, true
+}
+
+// detail get book identified by URI, response is written and false returned
+// when it can not be found
+func (ac *CoverHandler) detail(c *gin.Context) (*models.BookDBModel, bool) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return nil, false
+		}
+	}
+
+	book := new(models.BookDBModel)
+	book.ID, _ = strconv.Atoi(idURI.ID)
+
+	if err := ac.BookRepo.Detail(c.Request.Context(), book); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
+		} else {
+			internalError(c, err)
+		}
+		return nil, false
+	}
+
+	return book, true
+}
+
+// writeError write response for cover update error
+func (ac *CoverHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrVersionConflict):
+		preconditionFailed(c)
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"msg": "book not found"})
+	default:
+		internalError(c, err)
+	}
+}
+
+// remove delete cover objects from storage, failure only leave unused
+// objects behind so it is logged
+func (ac *CoverHandler) remove(ctx context.Context, objects *models.CoverObjects) {
+	for _, key := range []string{objects.Key, objects.ThumbnailKey} {
+		if err := ac.Store.Delete(ctx, key); err != nil {
+			log.Println("unable to remove cover object:", err)
+		}
+	}
+}
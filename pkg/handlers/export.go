@@ -0,0 +1,169 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"iter"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// Export media types
+const (
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// exportFlushRows rows written between flushes, each flush is sent as a
+// chunk of the response
+const exportFlushRows = 500
+
+// ExportHandler Controllers for catalog export
+type ExportHandler struct {
+	AuthorRepo repository.AuthorRepository
+	BookRepo   repository.BookRepository
+}
+
+// Authors export authors matching the same filters as list as CSV or NDJSON
+func (ac *ExportHandler) Authors(c *gin.Context) {
+	var filter models.AuthorFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+	filter.IncludeDeleted = includeDeleted(c)
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.AuthorSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	filter.SortKeys = sortKeys
+
+	export(c, "authors", models.AuthorCSVColumns, ac.AuthorRepo.Stream(c.Request.Context(), &filter), (*models.AuthorDBModel).CSVRecord)
+}
+
+// Books export books matching the same filters as list as CSV or NDJSON
+func (ac *ExportHandler) Books(c *gin.Context) {
+	var filter models.BookFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+	filter.IncludeDeleted = includeDeleted(c)
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.BookSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	filter.SortKeys = sortKeys
+
+	export(c, "books", models.BookCSVColumns, ac.BookRepo.Stream(c.Request.Context(), &filter), (*models.BookDBModel).CSVRecord)
+}
+
+// exportFormat format requested by format param or Accept header, CSV by
+// default
+func exportFormat(c *gin.Context) (string, bool) {
+	switch format := c.Query("format"); format {
+	case models.FormatCSV, models.FormatNDJSON:
+		return format, true
+	case "":
+		if strings.Contains(c.GetHeader("Accept"), MIMENDJSON) {
+			return models.FormatNDJSON, true
+		}
+		return models.FormatCSV, true
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "format must be csv or ndjson"})
+		return "", false
+	}
+}
+
+// export write every record of records as it is read, the response is
+// chunked and flushed every exportFlushRows rows so memory does not grow
+// with the number of records. Response is only started once the first
+// record is read, later failure can only cut the response short.
+func export[T any](c *gin.Context, name string, columns []string, records iter.Seq2[*T, error], record func(*T) []string) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	var w exportWriter
+	rows := 0
+	for m, err := range records {
+		if err != nil && !w.started() {
+			internalError(c, err)
+			return
+		} else if err != nil {
+			log.Println("export interrupted:", err)
+			c.Abort()
+			return
+		}
+
+		if !w.started() {
+			w.start(c, name, format, columns)
+		}
+		if err := write(&w, m, record); err != nil {
+			// client is gone
+			c.Abort()
+			return
+		}
+
+		if rows++; rows%exportFlushRows == 0 {
+			w.flush(c)
+		}
+	}
+
+	if !w.started() {
+		w.start(c, name, format, columns)
+	}
+	w.flush(c)
+}
+
+// exportWriter record encoder of export format
+type exportWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+// started tells whether response was started
+func (w *exportWriter) started() bool {
+	return w.csv != nil || w.json != nil
+}
+
+// start write export headers and CSV header row
+func (w *exportWriter) start(c *gin.Context, name, format string, columns []string) {
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	if format == models.FormatCSV {
+		c.Header("Content-Type", MIMECSV+"; charset=utf-8")
+		w.csv = csv.NewWriter(c.Writer)
+		w.csv.Write(columns)
+	} else {
+		c.Header("Content-Type", MIMENDJSON)
+		w.json = json.NewEncoder(c.Writer)
+	}
+	c.Status(http.StatusOK)
+}
+
+// write encode single record
+func write[T any](w *exportWriter, m *T, record func(*T) []string) error {
+	if w.csv != nil {
+		return w.csv.Write(record(m))
+	}
+
+	return w.json.Encode(m)
+}
+
+// flush send buffered rows to the client
+func (w *exportWriter) flush(c *gin.Context) {
+	if w.csv != nil {
+		w.csv.Flush()
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,168 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// GenreHandler Controllers for genre
+type GenreHandler struct {
+	GenreRepo repository.GenreRepository
+}
+
+// Add insert new genre record
+func (ac *GenreHandler) Add(c *gin.Context) {
+	var reqBody models.GenreBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	genre := new(models.GenreDBModel)
+	if err := ac.GenreRepo.Insert(c.Request.Context(), genre, &reqBody); err != nil {
+		writeGenreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, genre)
+}
+
+// List get the whole genre tree, each genre follow its parent
+func (ac *GenreHandler) List(c *gin.Context) {
+	genres, err := ac.GenreRepo.List(c.Request.Context())
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": genres})
+}
+
+// Get get detail genre by ID, book total change without touching the genre
+// so conditional GET is not answered
+func (ac *GenreHandler) Get(c *gin.Context) {
+	genre, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	setValidators(c, etag(genre.Version, genre.UpdatedAt), genre.UpdatedAt)
+	c.JSON(http.StatusOK, genre)
+}
+
+// Update update genre handler by ID, genre can be moved under another parent
+// but never under itself or its descendants
+func (ac *GenreHandler) Update(c *gin.Context) {
+	var reqBody models.GenreBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	genre, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(c, etag(genre.Version, genre.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	if err := ac.GenreRepo.Update(c.Request.Context(), genre, &reqBody); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writeGenreError(c, err)
+		return
+	}
+
+	setValidators(c, etag(genre.Version, genre.UpdatedAt), genre.UpdatedAt)
+	c.JSON(http.StatusOK, genre)
+}
+
+// Delete remove genre by ID handler, genre having sub-genres or books can not
+// be removed
+func (ac *GenreHandler) Delete(c *gin.Context) {
+	genre, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.GenreRepo.Delete(c.Request.Context(), genre); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"msg": "genre not found"})
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			c.JSON(http.StatusConflict, gin.H{"msg": "genre still has sub-genres or books, including the ones in trash"})
+		default:
+			internalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "Genre Removed"})
+}
+
+// detail get genre identified by URI, response is written and false returned
+// when it can not be found
+func (ac *GenreHandler) detail(c *gin.Context) (*models.GenreDBModel, bool) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return nil, false
+		}
+	}
+
+	genre := new(models.GenreDBModel)
+	genre.ID, _ = strconv.Atoi(idURI.ID)
+
+	if err := ac.GenreRepo.Detail(c.Request.Context(), genre); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "genre not found"})
+		} else {
+			internalError(c, err)
+		}
+		return nil, false
+	}
+
+	return genre, true
+}
+
+// writeGenreError write response for genre insert or update error
+func writeGenreError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, repository.ErrGenreCycle):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		c.JSON(http.StatusConflict, gin.H{"msg": "genre slug already registered"})
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "unknown parent genre"})
+	default:
+		internalError(c, err)
+	}
+}
@@ -2,27 +2,231 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/middleware"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/ratelimit"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/storage"
+	"github.com/kasfil/bookies/pkg/utilities"
 )
 
-// IncludeHandlers add defined controller to app
-func IncludeHandlers(app *gin.Engine) {
-	// Author Handler group
-	authorC := new(AuthorHandler)
-	author := app.Group("/authors")
-	author.GET("", authorC.Fetch)
-	author.POST("", authorC.Add)
-	author.GET("/:id", authorC.Get)
-	author.PUT("/:id", authorC.Update)
-	author.DELETE("/:id", authorC.Delete)
-	author.GET("/:id/books", authorC.Books)
-
-	// Books Handler group
-	bookH := new(BookHandler)
-	book := app.Group("/books")
-	book.GET("", bookH.Fetch)
-	book.POST("", bookH.Add)
-	book.GET("/:id", bookH.Get)
-	book.PUT("/:id", bookH.Update)
-	book.DELETE("/:id", bookH.Delete)
+// route single API route, the minimum role allowed to call it and its
+// Cache-Control policy
+type route struct {
+	method  string
+	path    string
+	role    string
+	cache   string
+	handler gin.HandlerFunc
+}
+
+// Cache-Control policies of routes
+const (
+	// cacheDetail single record, client should revalidate with its ETag
+	cacheDetail = "detail"
+	// cacheList list of records, may be reused shortly
+	cacheList = "list"
+	// cacheNone response must not be stored
+	cacheNone = "none"
+)
+
+// RouteGroups route groups which are rate limited separately, a route group
+// is the first segment of its path
+var RouteGroups = []string{"authors", "books", "publishers", "works", "series", "genres", "export", "import", "audit", "admin"}
+
+// IncludeHandlers add defined controller to app, every route is rate limited
+// by its group, authorized by its declared role and get its Cache-Control
+// policy. Uploaded files are kept in store.
+func IncludeHandlers(app *gin.Engine, repos *repository.Repositories, store storage.Storage, authn *auth.Auth, limiter *ratelimit.Limiter) {
+	authorC := &AuthorHandler{AuthorRepo: repos.Author, BookRepo: repos.Book}
+	bookH := &BookHandler{BookRepo: repos.Book}
+	coverH := &CoverHandler{
+		BookRepo:       repos.Book,
+		Store:          store,
+		MaxSize:        int64(utilities.EnvInt("COVER_MAX_BYTES", 5<<20)),
+		ThumbnailWidth: utilities.EnvInt("COVER_THUMBNAIL_WIDTH", 240),
+	}
+	publisherH := &PublisherHandler{PublisherRepo: repos.Publisher}
+	seriesH := &SeriesHandler{SeriesRepo: repos.Series, BookRepo: repos.Book}
+	genreH := &GenreHandler{GenreRepo: repos.Genre}
+	auditH := &AuditHandler{AuditRepo: repos.Audit}
+	apiKeyH := &APIKeyHandler{APIKeyRepo: repos.APIKey}
+	exportH := &ExportHandler{AuthorRepo: repos.Author, BookRepo: repos.Book}
+	importH := &ImportHandler{AuthorRepo: repos.Author, BookRepo: repos.Book}
+
+	routes := []route{
+		// Author routes
+		{http.MethodGet, "/authors", auth.RoleReader, cacheList, authorC.Fetch},
+		{http.MethodPost, "/authors", auth.RoleEditor, cacheNone, authorC.Add},
+		{http.MethodPost, "/authors/bulk", auth.RoleEditor, cacheNone, authorC.Bulk},
+		{http.MethodPost, "/authors/bulk/delete", auth.RoleAdmin, cacheNone, authorC.BulkDelete},
+		{http.MethodGet, "/authors/trash", auth.RoleEditor, cacheNone, authorC.Trash},
+		{http.MethodGet, "/authors/:id", auth.RoleReader, cacheDetail, authorC.Get},
+		{http.MethodPut, "/authors/:id", auth.RoleEditor, cacheNone, authorC.Update},
+		{http.MethodPatch, "/authors/:id", auth.RoleEditor, cacheNone, authorC.Patch},
+		{http.MethodDelete, "/authors/:id", auth.RoleAdmin, cacheNone, authorC.Delete},
+		{http.MethodPost, "/authors/:id/restore", auth.RoleAdmin, cacheNone, authorC.Restore},
+		{http.MethodGet, "/authors/:id/books", auth.RoleReader, cacheList, authorC.Books},
+		{http.MethodGet, "/authors/:id/history", auth.RoleEditor, cacheNone, auditH.AuthorHistory},
+
+		// Book routes
+		{http.MethodGet, "/books", auth.RoleReader, cacheList, bookH.Fetch},
+		{http.MethodPost, "/books", auth.RoleEditor, cacheNone, bookH.Add},
+		{http.MethodPost, "/books/bulk", auth.RoleEditor, cacheNone, bookH.Bulk},
+		{http.MethodPost, "/books/bulk/delete", auth.RoleAdmin, cacheNone, bookH.BulkDelete},
+		{http.MethodGet, "/books/trash", auth.RoleEditor, cacheNone, bookH.Trash},
+		{http.MethodGet, "/books/isbn/:isbn", auth.RoleReader, cacheDetail, bookH.ByISBN},
+		{http.MethodGet, "/books/:id", auth.RoleReader, cacheDetail, bookH.Get},
+		{http.MethodPut, "/books/:id", auth.RoleEditor, cacheNone, bookH.Update},
+		{http.MethodPatch, "/books/:id", auth.RoleEditor, cacheNone, bookH.Patch},
+		{http.MethodDelete, "/books/:id", auth.RoleAdmin, cacheNone, bookH.Delete},
+		{http.MethodPost, "/books/:id/restore", auth.RoleAdmin, cacheNone, bookH.Restore},
+		{http.MethodPost, "/books/:id/cover", auth.RoleEditor, cacheNone, coverH.Upload},
+		{http.MethodDelete, "/books/:id/cover", auth.RoleEditor, cacheNone, coverH.Delete},
+		{http.MethodGet, "/books/:id/history", auth.RoleEditor, cacheNone, auditH.BookHistory},
+
+		// Publisher routes
+		{http.MethodGet, "/publishers", auth.RoleReader, cacheList, publisherH.Fetch},
+		{http.MethodPost, "/publishers", auth.RoleEditor, cacheNone, publisherH.Add},
+		{http.MethodGet, "/publishers/:id", auth.RoleReader, cacheDetail, publisherH.Get},
+		{http.MethodPut, "/publishers/:id", auth.RoleEditor, cacheNone, publisherH.Update},
+		{http.MethodDelete, "/publishers/:id", auth.RoleAdmin, cacheNone, publisherH.Delete},
+		{http.MethodGet, "/publishers/:id/history", auth.RoleEditor, cacheNone, auditH.PublisherHistory},
+
+		// Work routes
+		{http.MethodGet, "/works/:id/editions", auth.RoleReader, cacheList, bookH.Editions},
+
+		// Series routes
+		{http.MethodGet, "/series", auth.RoleReader, cacheList, seriesH.Fetch},
+		{http.MethodPost, "/series", auth.RoleEditor, cacheNone, seriesH.Add},
+		{http.MethodGet, "/series/:id", auth.RoleReader, cacheDetail, seriesH.Get},
+		{http.MethodPut, "/series/:id", auth.RoleEditor, cacheNone, seriesH.Update},
+		{http.MethodDelete, "/series/:id", auth.RoleAdmin, cacheNone, seriesH.Delete},
+		{http.MethodGet, "/series/:id/books", auth.RoleReader, cacheList, seriesH.Books},
+		{http.MethodGet, "/series/:id/history", auth.RoleEditor, cacheNone, auditH.SeriesHistory},
+
+		// Genre routes
+		{http.MethodGet, "/genres", auth.RoleReader, cacheList, genreH.List},
+		{http.MethodPost, "/genres", auth.RoleEditor, cacheNone, genreH.Add},
+		{http.MethodGet, "/genres/:id", auth.RoleReader, cacheDetail, genreH.Get},
+		{http.MethodPut, "/genres/:id", auth.RoleEditor, cacheNone, genreH.Update},
+		{http.MethodDelete, "/genres/:id", auth.RoleAdmin, cacheNone, genreH.Delete},
+		{http.MethodGet, "/genres/:id/history", auth.RoleEditor, cacheNone, auditH.GenreHistory},
+
+		// Catalog transfer routes
+		{http.MethodGet, "/export/authors", auth.RoleReader, cacheNone, exportH.Authors},
+		{http.MethodGet, "/export/books", auth.RoleReader, cacheNone, exportH.Books},
+		{http.MethodPost, "/import", auth.RoleEditor, cacheNone, importH.Import},
+
+		// Administration routes
+		{http.MethodGet, "/audit", auth.RoleAdmin, cacheNone, auditH.Fetch},
+		{http.MethodGet, "/admin/api-keys", auth.RoleAdmin, cacheNone, apiKeyH.Fetch},
+		{http.MethodPost, "/admin/api-keys", auth.RoleAdmin, cacheNone, apiKeyH.Add},
+		{http.MethodDelete, "/admin/api-keys/:prefix", auth.RoleAdmin, cacheNone, apiKeyH.Revoke},
+	}
+
+	caching := map[string]gin.HandlerFunc{
+		cacheDetail: middleware.CacheControl(utilities.EnvString("CACHE_CONTROL_DETAIL", "private, no-cache")),
+		cacheList:   middleware.CacheControl(utilities.EnvString("CACHE_CONTROL_LIST", "private, max-age=5")),
+		cacheNone:   middleware.CacheControl("no-store"),
+	}
+
+	throttles := map[string]gin.HandlerFunc{}
+	for _, group := range RouteGroups {
+		throttles[group] = limiter.Middleware(group)
+	}
+
+	for _, r := range routes {
+		app.Handle(r.method, r.path, throttles[routeGroup(r.path)], authn.Allow(r.role), caching[r.cache], r.handler)
+	}
+}
+
+// routeGroup group of route path
+func routeGroup(path string) string {
+	group, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return group
+}
+
+// internalError write response for unexpected repository error. Query which
+// exceed the request timeout is reported as gateway timeout, and nothing is
+// written when the client already gone.
+func internalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidCursor):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "invalid cursor, it may belong to different sort order"})
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		log.Println(err)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"msg": "request timeout, please try again"})
+	case errors.Is(err, context.Canceled):
+		c.Abort()
+	default:
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"msg": "oops, we made a mistake"})
+	}
+}
+
+// bindQuery bind query params into obj, validation error response is written
+// and false returned when params are invalid
+func bindQuery(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return false
+	}
+
+	return true
+}
+
+// includeDeleted tells whether include_deleted param ask for deleted records
+// too, it is only honoured for editors so readers cannot bypass the trash
+func includeDeleted(c *gin.Context) bool {
+	include, _ := strconv.ParseBool(c.Query("include_deleted"))
+	if !include {
+		return false
+	}
+
+	principal, ok := auth.PrincipalFrom(c)
+	return ok && principal.Can(auth.RoleEditor)
+}
+
+// bindPagination read page, limit and cursor query params into p, cursor
+// param (even empty) switch list into keyset pagination
+func bindPagination(c *gin.Context, p *models.Pagination) bool {
+	// Get page value from query params
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "page parameter should be number and greater than 1"})
+		return false
+	}
+
+	// Get limit value from query params
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 5 || limit > 100 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "limit parameter should be number and between 5 and 100"})
+		return false
+	}
+
+	p.Limit = limit
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		p.Cursor = &cursor
+	} else {
+		p.Page = page
+	}
+
+	return true
 }
@@ -0,0 +1,606 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// ImportHandler Controllers for catalog import
+type ImportHandler struct {
+	AuthorRepo repository.AuthorRepository
+	BookRepo   repository.BookRepository
+}
+
+// importRow source row, fields hold every mapped field found in the row
+type importRow struct {
+	line   int
+	fields map[string]any
+}
+
+// importEntity entity specific parts of import
+type importEntity[M, D any] struct {
+	bulk bulkEntity[M, D]
+	// fields importable fields
+	fields []string
+	// cell value of CSV cell, nil keeps the text as is
+	cell func(field, value string) (any, error)
+	// resolve turn references of rows into fields, message of rejected row
+	// by index
+	resolve func(ctx context.Context, rows []importRow) (map[int]string, error)
+	// key upsert key of decoded row, empty when row can not match anything
+	key func(d *D) string
+	// find existing records by upsert key of rows
+	find func(ctx context.Context, rows []*D) (map[string][]M, error)
+	// base request body form of existing record
+	base func(m *M) D
+	// normalize row before compared with existing record, may be nil
+	normalize func(d *D)
+}
+
+// Import upsert authors or books from CSV or NDJSON upload
+func (ac *ImportHandler) Import(c *gin.Context) {
+	var params models.ImportParams
+	if !bindQuery(c, &params) {
+		return
+	}
+	params.Mapping = c.QueryMap("map")
+	if params.Mode == "" {
+		params.Mode = models.BulkAtomic
+	}
+
+	switch params.Entity {
+	case "authors":
+		runImport(c, &params, ac.authorImport())
+	default:
+		runImport(c, &params, ac.bookImport())
+	}
+}
+
+// authorImport authors are matched by email
+func (ac *ImportHandler) authorImport() importEntity[models.AuthorDBModel, models.AuthorBaseModel] {
+	return importEntity[models.AuthorDBModel, models.AuthorBaseModel]{
+		bulk:   authorBulk(ac.AuthorRepo),
+		fields: []string{"name", "email", "birth_date", "bio"},
+		key: func(d *models.AuthorBaseModel) string {
+			return d.Email
+		},
+		find: func(ctx context.Context, rows []*models.AuthorBaseModel) (map[string][]models.AuthorDBModel, error) {
+			emails := make([]string, len(rows))
+			for i, row := range rows {
+				emails[i] = row.Email
+			}
+
+			authors, err := ac.AuthorRepo.FindByEmail(ctx, emails)
+			found := map[string][]models.AuthorDBModel{}
+			for _, author := range authors {
+				found[author.Email] = append(found[author.Email], author)
+			}
+			return found, err
+		},
+		base: (*models.AuthorDBModel).Base,
+	}
+}
+
+// bookImport books are matched by ISBN when given otherwise by title and
+// primary author, primary author may be referenced by author_email
+func (ac *ImportHandler) bookImport() importEntity[models.BookDBModel, models.BookBaseModel] {
+	return importEntity[models.BookDBModel, models.BookBaseModel]{
+		bulk: bookBulk(ac.BookRepo),
+		fields: []string{
+			"title", "isbn", "description", "pub_date", "author_id", "author_email", "contributors",
+			"publisher_id", "language", "page_count", "format", "work_id", "series_id", "series_position",
+			"genres", "tags",
+		},
+		cell: func(field, value string) (any, error) {
+			switch field {
+			case "contributors":
+				return models.ParseContributors(value)
+			case "genres", "tags":
+				return models.ParseList(value), nil
+			case "series_position":
+				position, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s should be a number", field)
+				}
+				return position, nil
+			case "publisher_id", "page_count", "work_id", "series_id":
+				number, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("%s should be a number", field)
+				}
+				return number, nil
+			}
+			return value, nil
+		},
+		resolve: ac.resolveAuthors,
+		key: func(d *models.BookBaseModel) string {
+			if isbn := d.NormalizedISBN(); isbn != nil {
+				return "isbn:" + *isbn
+			}
+
+			primary, _ := d.ResolveContributors()
+			if primary == 0 || d.Title == "" {
+				return ""
+			}
+			return bookKey(models.BookKey{Title: d.Title, AuthorID: primary})
+		},
+		find: func(ctx context.Context, rows []*models.BookBaseModel) (map[string][]models.BookDBModel, error) {
+			var isbns []string
+			var keys []models.BookKey
+			for _, row := range rows {
+				if isbn := row.NormalizedISBN(); isbn != nil {
+					isbns = append(isbns, *isbn)
+					continue
+				}
+
+				key := models.BookKey{Title: row.Title}
+				key.AuthorID, _ = row.ResolveContributors()
+				keys = append(keys, key)
+			}
+
+			found := map[string][]models.BookDBModel{}
+			if len(isbns) > 0 {
+				books, err := ac.BookRepo.FindByISBN(ctx, isbns)
+				if err != nil {
+					return nil, err
+				}
+				for _, book := range books {
+					key := "isbn:" + *book.ISBN
+					found[key] = append(found[key], book)
+				}
+			}
+
+			if len(keys) > 0 {
+				books, err := ac.BookRepo.FindByTitle(ctx, keys)
+				if err != nil {
+					return nil, err
+				}
+				for _, book := range books {
+					key := bookKey(models.BookKey{Title: book.Title, AuthorID: book.Author.ID})
+					found[key] = append(found[key], book)
+				}
+			}
+			return found, nil
+		},
+		base: (*models.BookDBModel).Base,
+		normalize: func(d *models.BookBaseModel) {
+			for i := range d.Contributors {
+				if d.Contributors[i].Position == nil {
+					position := i
+					d.Contributors[i].Position = &position
+				}
+			}
+		},
+	}
+}
+
+// bookKey upsert key of book, title is case insensitive
+func bookKey(key models.BookKey) string {
+	return strconv.Itoa(key.AuthorID) + ":" + strings.ToLower(key.Title)
+}
+
+// resolveAuthors replace author_email of rows with author_id of the author
+// having it, numeric author_id of NDJSON row is turned into text
+func (ac *ImportHandler) resolveAuthors(ctx context.Context, rows []importRow) (map[int]string, error) {
+	var emails []string
+	for _, row := range rows {
+		if email, ok := row.fields["author_email"].(string); ok && email != "" {
+			emails = append(emails, email)
+		}
+	}
+
+	ids := map[string]int{}
+	if len(emails) > 0 {
+		authors, err := ac.AuthorRepo.FindByEmail(ctx, emails)
+		if err != nil {
+			return nil, err
+		}
+		for _, author := range authors {
+			ids[author.Email] = author.ID
+		}
+	}
+
+	rejected := map[int]string{}
+	for i, row := range rows {
+		if id, ok := row.fields["author_id"].(float64); ok {
+			row.fields["author_id"] = strconv.FormatFloat(id, 'f', -1, 64)
+		}
+
+		email, ok := row.fields["author_email"].(string)
+		delete(row.fields, "author_email")
+		if !ok || email == "" {
+			continue
+		}
+
+		if id, ok := ids[email]; !ok {
+			rejected[i] = "unknown author email " + email
+		} else if _, given := row.fields["author_id"]; !given {
+			row.fields["author_id"] = strconv.Itoa(id)
+		}
+	}
+
+	return rejected, nil
+}
+
+// runImport read rows of upload, validate them and upsert the valid ones.
+// Row matching existing record only update the fields which changed, dry
+// run report what would be written without writing anything.
+func runImport[M, D any](c *gin.Context, params *models.ImportParams, e importEntity[M, D]) {
+	for field := range params.Mapping {
+		if !slices.Contains(e.fields, field) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": fmt.Sprintf("unknown %s field %q in map, fields are %s", e.bulk.name, field, strings.Join(e.fields, ", "))})
+			return
+		}
+	}
+
+	rows, ok := readImport(c, params, e.fields, e.cell)
+	if !ok {
+		return
+	}
+
+	report := models.ImportReport{Entity: params.Entity, Format: params.Format, Mode: params.Mode, DryRun: params.DryRun}
+	report.Rows = make([]models.ImportRow, len(rows))
+	for i, row := range rows {
+		report.Rows[i] = models.ImportRow{Line: row.line, Action: models.ImportInvalid, BulkResult: models.BulkResult{Index: i}}
+	}
+
+	ctx := c.Request.Context()
+	rejected := map[int]string{}
+	if e.resolve != nil {
+		var err error
+		if rejected, err = e.resolve(ctx, rows); err != nil {
+			internalError(c, err)
+			return
+		}
+	}
+
+	// decode rows alone first to know their upsert key
+	decoded := make([]*D, len(rows))
+	var keyed []*D
+	for i, row := range rows {
+		if msg, ok := rejected[i]; ok {
+			report.Rows[i].Status, report.Rows[i].Msg = http.StatusUnprocessableEntity, msg
+			continue
+		}
+
+		d := new(D)
+		if err := decodeFields(nil, row.fields, d); err != nil {
+			report.Rows[i].Status, report.Rows[i].Msg = http.StatusUnprocessableEntity, err.Error()
+			continue
+		}
+		decoded[i] = d
+		if e.key(d) != "" {
+			keyed = append(keyed, d)
+		}
+	}
+
+	found := map[string][]M{}
+	if len(keyed) > 0 {
+		var err error
+		if found, err = e.find(ctx, keyed); err != nil {
+			internalError(c, err)
+			return
+		}
+	}
+
+	var ops []repository.BulkOp[M, D]
+	var opResults []*models.BulkResult
+	invalid := false
+	lines := map[string]int{}
+	for i, d := range decoded {
+		row := &report.Rows[i]
+		if d == nil {
+			invalid = true
+			continue
+		}
+
+		key := e.key(d)
+		if line, ok := lines[key]; ok {
+			row.Status, row.Msg = http.StatusUnprocessableEntity, fmt.Sprintf("duplicate of line %d", line)
+			invalid = true
+			continue
+		} else if key != "" {
+			lines[key] = rows[i].line
+		}
+
+		op := repository.BulkOp[M, D]{Action: repository.BulkCreate, Model: e.bulk.model(0, 0), Data: d}
+		var current any
+		switch matches := found[key]; {
+		case len(matches) > 1:
+			row.Status, row.Msg = http.StatusConflict, fmt.Sprintf("%d %ss match this row", len(matches), e.bulk.name)
+			invalid = true
+			continue
+		case len(matches) == 1:
+			base := e.base(&matches[0])
+			current = base
+			op.Action, op.Model, op.Data = repository.BulkUpdate, &matches[0], new(D)
+			if err := decodeFields(base, rows[i].fields, op.Data); err != nil {
+				row.Status, row.Msg = http.StatusUnprocessableEntity, err.Error()
+				invalid = true
+				continue
+			}
+		}
+
+		if e.normalize != nil {
+			e.normalize(op.Data)
+		}
+		row.Action = models.ImportCreate
+		if op.Action == repository.BulkUpdate {
+			row.Action = models.ImportUpdate
+		}
+		if !validItem(&row.BulkResult, op.Data) {
+			row.Action = models.ImportInvalid
+			invalid = true
+			continue
+		}
+
+		if current != nil {
+			fields, err := changedFields(current, op.Data)
+			if err != nil {
+				internalError(c, err)
+				return
+			}
+			if len(fields) == 0 {
+				row.Action, row.Status = models.ImportUnchanged, http.StatusOK
+				row.ID, row.Version = e.bulk.stamp(op.Model)
+				continue
+			}
+			op.Fields = fields
+		}
+
+		ops = append(ops, op)
+		opResults = append(opResults, &row.BulkResult)
+	}
+
+	if params.DryRun {
+		for j, op := range ops {
+			opResults[j].ID, opResults[j].Version = e.bulk.stamp(op.Model)
+			opResults[j].Status = http.StatusOK
+			if op.Action == repository.BulkCreate {
+				opResults[j].Status = http.StatusCreated
+			}
+		}
+	} else if !writeOps(c, e.bulk, ops, opResults, params.Mode == models.BulkAtomic, invalid) {
+		return
+	}
+
+	writeImport(c, &report)
+}
+
+// decodeFields decode fields laid over JSON form of base into obj, base may
+// be nil
+func decodeFields(base any, fields map[string]any, obj any) error {
+	merged := map[string]any{}
+	if base != nil {
+		var err error
+		if merged, err = jsonFields(base); err != nil {
+			return err
+		}
+	}
+	for field, value := range fields {
+		merged[field] = value
+	}
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, obj)
+}
+
+// writeImport count rows by outcome and write import report, dry run is
+// always 200 otherwise the same status as bulk write
+func writeImport(c *gin.Context, report *models.ImportReport) {
+	for _, row := range report.Rows {
+		switch {
+		case row.Status >= http.StatusBadRequest:
+			report.Failed++
+		case row.Action == models.ImportCreate:
+			report.Created++
+		case row.Action == models.ImportUpdate:
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+	}
+
+	switch {
+	case report.Failed == 0 || report.DryRun:
+		c.JSON(http.StatusOK, report)
+	case report.Mode == models.BulkBestEffort:
+		c.JSON(http.StatusMultiStatus, report)
+	default:
+		c.JSON(http.StatusUnprocessableEntity, report)
+	}
+}
+
+// importFormat format of upload from format param, file extension or
+// content type
+func importFormat(format, filename, contentType string) string {
+	if format != "" {
+		return format
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.FormatCSV
+	case ".ndjson", ".jsonl":
+		return models.FormatNDJSON
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case MIMECSV:
+		return models.FormatCSV
+	case MIMENDJSON, "application/jsonl":
+		return models.FormatNDJSON
+	}
+
+	return ""
+}
+
+// readImport read rows of upload from file field of multipart form or the
+// request body, only fields are kept. Response is written and false
+// returned when upload can not be read.
+func readImport(c *gin.Context, params *models.ImportParams, fields []string, cell func(field, value string) (any, error)) ([]importRow, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(utilities.EnvInt("IMPORT_MAX_BYTES", 32<<20)))
+
+	var source io.Reader = c.Request.Body
+	var filename string
+	contentType := c.ContentType()
+	if strings.HasPrefix(contentType, "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "file field is required"})
+			return nil, false
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			internalError(c, err)
+			return nil, false
+		}
+		defer file.Close()
+
+		source, filename, contentType = file, header.Filename, header.Header.Get("Content-Type")
+	}
+
+	params.Format = importFormat(params.Format, filename, contentType)
+
+	var rows []importRow
+	var err error
+	switch params.Format {
+	case models.FormatCSV:
+		rows, err = readCSV(source, params.Mapping, fields, cell)
+	case models.FormatNDJSON:
+		rows, err = readNDJSON(source, params.Mapping, fields)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"msg": "upload must be " + MIMECSV + " or " + MIMENDJSON})
+		return nil, false
+	}
+
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "upload too large"})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return nil, false
+	case len(rows) == 0:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "upload has no row"})
+		return nil, false
+	case len(rows) > utilities.EnvInt("IMPORT_MAX_ROWS", 10000):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "upload has too many rows"})
+		return nil, false
+	}
+
+	return rows, true
+}
+
+// sourceName source column or key of field
+func sourceName(mapping map[string]string, field string) string {
+	if name, ok := mapping[field]; ok {
+		return name
+	}
+
+	return field
+}
+
+// readCSV read CSV rows, the first row names the columns. Empty cell is
+// read as null.
+func readCSV(source io.Reader, mapping map[string]string, fields []string, cell func(field, value string) (any, error)) ([]importRow, error) {
+	reader := csv.NewReader(source)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for _, field := range fields {
+		if i := slices.Index(header, sourceName(mapping, field)); i >= 0 {
+			columns[field] = i
+		} else if _, mapped := mapping[field]; mapped {
+			return nil, fmt.Errorf("column %q of %s is not found", mapping[field], field)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line, fields: map[string]any{}}
+		for field, i := range columns {
+			value := record[i]
+			if value == "" {
+				row.fields[field] = nil
+				continue
+			}
+
+			if cell == nil {
+				row.fields[field] = value
+			} else if row.fields[field], err = cell(field, value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readNDJSON read one JSON object per line, blank lines are skipped
+func readNDJSON(source io.Reader, mapping map[string]string, fields []string) ([]importRow, error) {
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var object map[string]any
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		row := importRow{line: line, fields: map[string]any{}}
+		for _, field := range fields {
+			if value, ok := object[sourceName(mapping, field)]; ok {
+				row.fields[field] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
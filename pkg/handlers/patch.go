@@ -0,0 +1,138 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// Patch document media types
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// maxPatchSize largest accepted patch document
+const maxPatchSize = 1 << 20
+
+// applyPatch apply request patch document on current request body
+// representation of record and decode the result into obj, validated with
+// the same rules as full update. JSON Merge Patch (RFC 7396) is used for
+// merge-patch and plain JSON body, JSON Patch (RFC 6902) for json-patch.
+// Top level fields whose value changed are returned, response is written
+// and false returned when patch can not be applied.
+func applyPatch(c *gin.Context, current any, obj any) ([]string, bool) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType != MergePatchType && mediaType != JSONPatchType && mediaType != gin.MIMEJSON {
+		c.Header("Accept-Patch", MergePatchType+", "+JSONPatchType)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"msg": "patch must be " + MergePatchType + " or " + JSONPatchType})
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"msg": "patch document too large"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"msg": "unable to read patch document"})
+		}
+		return nil, false
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		internalError(c, err)
+		return nil, false
+	}
+
+	var patched []byte
+	if mediaType == JSONPatchType {
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			patched, err = patch.Apply(original)
+		}
+	} else {
+		patched, err = jsonpatch.MergePatch(original, body)
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		c.JSON(http.StatusConflict, gin.H{"msg": "patch test operation failed"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "invalid patch document: " + err.Error()})
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": "invalid patched record: " + err.Error()})
+		return nil, false
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			internalError(c, err)
+		}
+		return nil, false
+	}
+
+	fields, err := changedFields(current, obj)
+	if err != nil {
+		internalError(c, err)
+		return nil, false
+	}
+
+	return fields, true
+}
+
+// changedFields top level JSON fields whose value differ between before and
+// after, sorted by name
+func changedFields(before, after any) ([]string, error) {
+	from, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for key, value := range to {
+		if !reflect.DeepEqual(from[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+// jsonFields top level JSON fields of v
+func jsonFields(v any) (map[string]any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
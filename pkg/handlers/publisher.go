@@ -0,0 +1,187 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// PublisherHandler Controllers for publisher
+type PublisherHandler struct {
+	PublisherRepo repository.PublisherRepository
+}
+
+// Add insert new publisher record
+func (ac *PublisherHandler) Add(c *gin.Context) {
+	var reqBody models.PublisherBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	publisher := new(models.PublisherDBModel)
+	if err := ac.PublisherRepo.Insert(c.Request.Context(), publisher, &reqBody); err != nil {
+		writePublisherError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, publisher)
+}
+
+// Fetch get list of publishers from database
+func (ac *PublisherHandler) Fetch(c *gin.Context) {
+	var page models.Pagination
+	if !bindPagination(c, &page) {
+		return
+	}
+
+	var filter models.PublisherFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.PublisherSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	filter.SortKeys = sortKeys
+
+	publishers := new(models.FetchPublisherDBModel)
+	publishers.Pagination = page
+
+	if err := ac.PublisherRepo.Fetch(c.Request.Context(), publishers, &filter); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, publishers)
+}
+
+// Get get detail publisher by ID
+func (ac *PublisherHandler) Get(c *gin.Context) {
+	publisher, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	tag := etag(publisher.Version, publisher.UpdatedAt)
+	setValidators(c, tag, publisher.UpdatedAt)
+	if notModified(c, tag, publisher.UpdatedAt) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, publisher)
+}
+
+// Update update publisher handler by ID
+func (ac *PublisherHandler) Update(c *gin.Context) {
+	var reqBody models.PublisherBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	publisher, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(c, etag(publisher.Version, publisher.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	if err := ac.PublisherRepo.Update(c.Request.Context(), publisher, &reqBody); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writePublisherError(c, err)
+		return
+	}
+
+	setValidators(c, etag(publisher.Version, publisher.UpdatedAt), publisher.UpdatedAt)
+	c.JSON(http.StatusOK, publisher)
+}
+
+// Delete remove publisher by ID handler, publisher having books can not be
+// removed
+func (ac *PublisherHandler) Delete(c *gin.Context) {
+	publisher, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.PublisherRepo.Delete(c.Request.Context(), publisher); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"msg": "publisher not found"})
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			c.JSON(http.StatusConflict, gin.H{"msg": "publisher still has books, including the ones in trash"})
+		default:
+			internalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "Publisher Removed"})
+}
+
+// detail get publisher identified by URI, response is written and false
+// returned when it can not be found
+func (ac *PublisherHandler) detail(c *gin.Context) (*models.PublisherDBModel, bool) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return nil, false
+		}
+	}
+
+	publisher := new(models.PublisherDBModel)
+	publisher.ID, _ = strconv.Atoi(idURI.ID)
+
+	if err := ac.PublisherRepo.Detail(c.Request.Context(), publisher); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "publisher not found"})
+		} else {
+			internalError(c, err)
+		}
+		return nil, false
+	}
+
+	return publisher, true
+}
+
+// writePublisherError write response for publisher insert or update error
+func writePublisherError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"msg": "publisher name already registered"})
+		return
+	}
+
+	internalError(c, err)
+}
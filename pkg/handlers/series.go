@@ -0,0 +1,228 @@
+// Package handlers All API handlers
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// SeriesHandler Controllers for series
+type SeriesHandler struct {
+	SeriesRepo repository.SeriesRepository
+	BookRepo   repository.BookRepository
+}
+
+// Add insert new series record
+func (ac *SeriesHandler) Add(c *gin.Context) {
+	var reqBody models.SeriesBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	series := new(models.SeriesDBModel)
+	if err := ac.SeriesRepo.Insert(c.Request.Context(), series, &reqBody); err != nil {
+		writeSeriesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// Fetch get list of series from database
+func (ac *SeriesHandler) Fetch(c *gin.Context) {
+	var page models.Pagination
+	if !bindPagination(c, &page) {
+		return
+	}
+
+	var filter models.SeriesFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.SeriesSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	filter.SortKeys = sortKeys
+
+	list := new(models.FetchSeriesDBModel)
+	list.Pagination = page
+
+	if err := ac.SeriesRepo.Fetch(c.Request.Context(), list, &filter); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// Get get detail series by ID
+func (ac *SeriesHandler) Get(c *gin.Context) {
+	series, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	tag := etag(series.Version, series.UpdatedAt)
+	setValidators(c, tag, series.UpdatedAt)
+	if notModified(c, tag, series.UpdatedAt) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// Update update series handler by ID
+func (ac *SeriesHandler) Update(c *gin.Context) {
+	var reqBody models.SeriesBaseModel
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			c.JSON(http.StatusUnprocessableEntity, utilities.ParseValidationError(validatorErr))
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		}
+		return
+	}
+
+	series, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(c, etag(series.Version, series.UpdatedAt)) {
+		preconditionFailed(c)
+		return
+	}
+
+	if err := ac.SeriesRepo.Update(c.Request.Context(), series, &reqBody); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(c)
+			return
+		}
+		writeSeriesError(c, err)
+		return
+	}
+
+	setValidators(c, etag(series.Version, series.UpdatedAt), series.UpdatedAt)
+	c.JSON(http.StatusOK, series)
+}
+
+// Books get books of series, in reading order unless sorted otherwise
+func (ac *SeriesHandler) Books(c *gin.Context) {
+	series, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	var page models.Pagination
+	if !bindPagination(c, &page) {
+		return
+	}
+
+	var filter models.BookFilter
+	if !bindQuery(c, &filter) {
+		return
+	}
+	filter.IncludeDeleted = includeDeleted(c)
+
+	sortKeys, err := models.ParseSort(filter.Sort, models.BookSortFields)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"msg": err.Error()})
+		return
+	}
+	if len(sortKeys) == 0 {
+		sortKeys = []models.SortKey{{Field: "series_position"}}
+	}
+	filter.SortKeys = sortKeys
+	filter.SeriesID = &series.ID
+
+	books := new(models.FetchBookDBModel)
+	books.Pagination = page
+
+	if err := ac.BookRepo.Fetch(c.Request.Context(), books, &filter); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, books)
+}
+
+// Delete remove series by ID handler, series having books can not be
+// removed
+func (ac *SeriesHandler) Delete(c *gin.Context) {
+	series, ok := ac.detail(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.SeriesRepo.Delete(c.Request.Context(), series); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"msg": "series not found"})
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			c.JSON(http.StatusConflict, gin.H{"msg": "series still has books, including the ones in trash"})
+		default:
+			internalError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "Series Removed"})
+}
+
+// detail get series identified by URI, response is written and false
+// returned when it can not be found
+func (ac *SeriesHandler) detail(c *gin.Context) (*models.SeriesDBModel, bool) {
+	var idURI models.IdentifierURI
+	if err := c.ShouldBindUri(&idURI); err != nil {
+		if validatorErr, ok := err.(validator.ValidationErrors); ok {
+			msgs := utilities.ParseValidationError(validatorErr)
+			c.JSON(http.StatusUnprocessableEntity, msgs)
+			return nil, false
+		}
+	}
+
+	series := new(models.SeriesDBModel)
+	series.ID, _ = strconv.Atoi(idURI.ID)
+
+	if err := ac.SeriesRepo.Detail(c.Request.Context(), series); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"msg": "series not found"})
+		} else {
+			internalError(c, err)
+		}
+		return nil, false
+	}
+
+	return series, true
+}
+
+// writeSeriesError write response for series insert or update error
+func writeSeriesError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"msg": "series name already registered"})
+		return
+	}
+
+	internalError(c, err)
+}
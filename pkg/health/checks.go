@@ -0,0 +1,56 @@
+// Package health Application liveness and readiness probes
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/migrate"
+)
+
+// MigrationCheck fail when database schema is behind the embedded
+// migrations or left dirty by a failed migration. Embedded migrations are
+// read once, the check keep failing when they are invalid.
+func MigrationCheck(db *database.DbPool) Check {
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return func(context.Context) error {
+			return err
+		}
+	}
+
+	return func(ctx context.Context) error {
+		version, dirty, ok, err := migrator.Version(ctx)
+		switch {
+		case err != nil:
+			return err
+		case !ok:
+			return errors.New("database not migrated")
+		case dirty:
+			return fmt.Errorf("migration %d is dirty", version)
+		case version < migrator.Latest():
+			return fmt.Errorf("pending migrations, database at %d expected %d", version, migrator.Latest())
+		}
+
+		return nil
+	}
+}
+
+// PoolSaturationCheck fail when acquired connection ratio reach threshold
+func PoolSaturationCheck(db *database.DbPool, threshold float64) Check {
+	return func(ctx context.Context) error {
+		stat := db.Conn.Stat()
+		if stat.MaxConns() == 0 {
+			return nil
+		}
+
+		ratio := float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+		if ratio >= threshold {
+			return fmt.Errorf("pool saturated, %d of %d connections acquired", stat.AcquiredConns(), stat.MaxConns())
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+// Package health Application liveness and readiness probes
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// Check single readiness check, returning error marks the check failed
+type Check func(ctx context.Context) error
+
+// CheckResult outcome of a single check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report probe response body
+type Report struct {
+	Status string                 `json:"status"`
+	Uptime string                 `json:"uptime,omitempty"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Probe hold application readiness state and its checks
+type Probe struct {
+	// Timeout maximum time a single check may take
+	Timeout time.Duration
+
+	startedAt time.Time
+	checks    map[string]Check
+	draining  atomic.Bool
+}
+
+// NewProbe create probe in ready state with database, migrations and pool
+// saturation checks
+func NewProbe(db *database.DbPool) *Probe {
+	p := &Probe{
+		Timeout:   utilities.EnvDuration("PROBE_TIMEOUT", 2*time.Second),
+		startedAt: time.Now(),
+		checks:    map[string]Check{},
+	}
+
+	if db != nil {
+		p.AddCheck("database", db.Ping)
+		p.AddCheck("migrations", MigrationCheck(db))
+		p.AddCheck("pool", PoolSaturationCheck(db, utilities.EnvFloat("POOL_SATURATION_THRESHOLD", 0.9)))
+	}
+
+	return p
+}
+
+// AddCheck register named readiness check
+func (p *Probe) AddCheck(name string, check Check) {
+	p.checks[name] = check
+}
+
+// SetDraining mark application as draining, readiness is reported unhealthy
+// afterwards so load balancer stop routing new request to this instance
+func (p *Probe) SetDraining(draining bool) {
+	p.draining.Store(draining)
+}
+
+// Draining tells whether application is shutting down
+func (p *Probe) Draining() bool {
+	return p.draining.Load()
+}
+
+// Liveness liveness probe handler, report ok as long as process can serve
+func (p *Probe) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{
+		Status: "ok",
+		Uptime: time.Since(p.startedAt).Round(time.Second).String(),
+	})
+}
+
+// Readiness readiness probe handler, run every registered check
+func (p *Probe) Readiness(c *gin.Context) {
+	if p.Draining() {
+		c.JSON(http.StatusServiceUnavailable, Report{Status: "draining"})
+		return
+	}
+
+	report := p.Run(c.Request.Context())
+	if report.Status != "ok" {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Run execute every check and collect their result
+func (p *Probe) Run(ctx context.Context) Report {
+	report := Report{Status: "ok", Checks: make(map[string]CheckResult, len(p.checks))}
+	for name, check := range p.checks {
+		result := p.run(ctx, check)
+		if result.Status != "ok" {
+			report.Status = "fail"
+		}
+		report.Checks[name] = result
+	}
+
+	return report
+}
+
+// run execute single check bounded by probe timeout
+func (p *Probe) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,87 @@
+// Package jobs Background jobs running alongside the REST server
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kasfil/bookies/pkg/audit"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// Purger data store which can permanently remove records deleted before
+// given time
+type Purger interface {
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// purgeTarget named data store to purge
+type purgeTarget struct {
+	name   string
+	purger Purger
+}
+
+// PurgeJob permanently remove soft deleted records once they are kept in
+// trash longer than retention period
+type PurgeJob struct {
+	// Retention how long deleted record stay restorable
+	Retention time.Duration
+	// Interval time between purge runs, zero disable the job
+	Interval time.Duration
+
+	targets []purgeTarget
+}
+
+// NewPurgeJob create purge job of books and authors trash
+func NewPurgeJob(repos *repository.Repositories) *PurgeJob {
+	return &PurgeJob{
+		Retention: utilities.EnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		Interval:  utilities.EnvDuration("PURGE_INTERVAL", time.Hour),
+		// books go first, purging author cascade the rest of its books
+		targets: []purgeTarget{
+			{name: "books", purger: repos.Book},
+			{name: "authors", purger: repos.Author},
+		},
+	}
+}
+
+// Run purge trash every interval until ctx is done
+func (j *PurgeJob) Run(ctx context.Context) {
+	if j.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Failed to purge trash", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce permanently remove every record deleted before retention period
+func (j *PurgeJob) RunOnce(ctx context.Context) error {
+	ctx = audit.WithActor(ctx, audit.System)
+	before := time.Now().Add(-j.Retention)
+	for _, target := range j.targets {
+		purged, err := target.purger.Purge(ctx, before)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("%d %s purged from trash", purged, target.name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+// Package middleware Gin middlewares shared by every route
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// CacheControl set Cache-Control header of every response of the route,
+// empty policy leave the header out
+func CacheControl(policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy != "" {
+			c.Header("Cache-Control", policy)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,40 @@
+// Package middleware Gin middlewares shared by every route
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/audit"
+)
+
+// RequestIDHeader header carrying request ID in request and response
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID accepted client supplied request ID
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID keep client supplied X-Request-ID or generate new one, the ID is
+// echoed back in response and attached to request context for audit events
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(audit.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// newRequestID random 128 bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,43 @@
+// Package middleware Gin middlewares shared by every route
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteTimeout timeout of requests whose path start with Prefix
+type RouteTimeout struct {
+	Prefix  string
+	Timeout time.Duration
+}
+
+// QueryTimeout bound the request context with timeout, every database query
+// run with the request context is cancelled once the timeout is reached.
+// Request matching one of routes use its timeout instead, the first match
+// win. Zero or negative timeout disables the bound.
+func QueryTimeout(timeout time.Duration, routes ...RouteTimeout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := timeout
+		for _, route := range routes {
+			if strings.HasPrefix(c.Request.URL.Path, route.Prefix) {
+				limit = route.Timeout
+				break
+			}
+		}
+
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limit)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,306 @@
+// Package migrate Database schema migration runner for embedded migrations.
+// Applied version is stored in golang-migrate compatible schema_migrations
+// table, so database previously migrated with migrate cli keep working.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kasfil/bookies/migrations"
+	"github.com/kasfil/bookies/pkg/database"
+)
+
+// lockKey advisory lock key, prevent concurrent migration from many replicas
+const lockKey = 7290350126
+
+var (
+	// ErrDirty database left dirty by failed migration, need to be forced
+	ErrDirty = errors.New("database is dirty, fix it and run force")
+	// ErrNoChange nothing to migrate
+	ErrNoChange = errors.New("no change")
+	// ErrUnknownVersion applied version is not part of embedded migrations
+	ErrUnknownVersion = errors.New("applied version not found in migrations")
+)
+
+// Status migration with its applied state
+type Status struct {
+	migrations.Migration
+	Applied bool
+}
+
+// Migrator apply embedded migrations to database
+type Migrator struct {
+	db     *database.DbPool
+	source fs.FS
+	list   []migrations.Migration
+}
+
+// New create migrator for embedded migrations
+func New(db *database.DbPool) (*Migrator, error) {
+	list, err := migrations.List()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, source: migrations.FS, list: list}, nil
+}
+
+// Version get current schema version, ok is false when no migration applied
+func (m *Migrator) Version(ctx context.Context) (version uint64, dirty bool, ok bool, err error) {
+	return readVersion(ctx, m.db.Conn)
+}
+
+// Latest get the highest available migration version
+func (m *Migrator) Latest() uint64 {
+	if len(m.list) == 0 {
+		return 0
+	}
+
+	return m.list[len(m.list)-1].Version
+}
+
+// Up apply every pending migration, each in its own transaction
+func (m *Migrator) Up(ctx context.Context) (applied int, err error) {
+	err = m.locked(ctx, func(conn *pgxpool.Conn) error {
+		version, ok, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.list {
+			if ok && mig.Version <= version {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, mig.Up, &mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+
+		return nil
+	})
+	if err == nil && applied == 0 {
+		err = ErrNoChange
+	}
+
+	return applied, err
+}
+
+// Down revert n latest applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) (reverted int, err error) {
+	err = m.locked(ctx, func(conn *pgxpool.Conn) error {
+		version, ok, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for ; ok && reverted < n; reverted++ {
+			idx := m.index(version)
+			if idx < 0 {
+				return ErrUnknownVersion
+			}
+
+			// previous version, nil when reverting the first migration
+			var prev *uint64
+			if idx > 0 {
+				prev = &m.list[idx-1].Version
+			}
+
+			mig := m.list[idx]
+			if err := m.apply(ctx, conn, mig.Down, prev); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			if prev == nil {
+				ok = false
+			} else {
+				version = *prev
+			}
+		}
+
+		return nil
+	})
+	if err == nil && reverted == 0 {
+		err = ErrNoChange
+	}
+
+	return reverted, err
+}
+
+// Force set schema version without running migration and clear dirty state,
+// nil version mark database as never migrated
+func (m *Migrator) Force(ctx context.Context, version *uint64) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn) error {
+		if version != nil && m.index(*version) < 0 {
+			return ErrUnknownVersion
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		// Rollback is a no-op once the transaction is committed
+		defer tx.Rollback(ctx)
+
+		if err := setVersion(ctx, tx, version); err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
+}
+
+// Status list every migration with its applied state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	version, _, ok, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Status, len(m.list))
+	for i, mig := range m.list {
+		list[i] = Status{Migration: mig, Applied: ok && mig.Version <= version}
+	}
+
+	return list, nil
+}
+
+// Create write new empty up and down migration file into dir
+func Create(dir, name string) (up string, down string, err error) {
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return "", "", errors.New("migration name should only contain lowercase letter, digit and underscore")
+	}
+
+	prefix := fmt.Sprintf("%s_%s", time.Now().UTC().Format("20060102150405"), name)
+	up = filepath.Join(dir, prefix+".up.sql")
+	down = filepath.Join(dir, prefix+".down.sql")
+
+	for _, file := range []string{up, down} {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		f.Close()
+	}
+
+	return up, down, nil
+}
+
+// apply run migration script and store version in single transaction
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, file string, version *uint64) error {
+	script, err := fs.ReadFile(m.source, file)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, string(script)); err != nil {
+		return err
+	}
+
+	if err := setVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// cleanVersion get current version and ensure database is not dirty
+func (m *Migrator) cleanVersion(ctx context.Context, conn *pgxpool.Conn) (uint64, bool, error) {
+	version, dirty, ok, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if dirty {
+		return 0, false, fmt.Errorf("version %d: %w", version, ErrDirty)
+	}
+
+	return version, ok, nil
+}
+
+// index find position of version in migration list
+func (m *Migrator) index(version uint64) int {
+	for i, mig := range m.list {
+		if mig.Version == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// locked run fn on single connection holding migration advisory lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint NOT NULL PRIMARY KEY,
+	dirty boolean NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// querier anything able to run single row query
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// readVersion read stored version, missing table means never migrated
+func readVersion(ctx context.Context, q querier) (version uint64, dirty bool, ok bool, err error) {
+	err = q.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "42P01") {
+			return 0, false, false, nil
+		}
+		return 0, false, false, err
+	}
+
+	return version, dirty, true, nil
+}
+
+// setVersion replace stored version, nil version clear it
+func setVersion(ctx context.Context, tx pgx.Tx, version *uint64) error {
+	if _, err := tx.Exec(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return err
+	}
+
+	if version == nil {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", *version)
+	return err
+}
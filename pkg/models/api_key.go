@@ -0,0 +1,35 @@
+// Package models Application structure model
+package models
+
+import "time"
+
+// APIKeyDBModel API key database record, only hash of the secret is stored
+type APIKeyDBModel struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    []byte     `json:"-" db:"key_hash"`
+	Roles      []string   `json:"roles" db:"roles"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// APIKeyBaseModel API key request body, case for creating new key
+type APIKeyBaseModel struct {
+	Name      string     `json:"name" binding:"required,gte=1,lte=64"`
+	Roles     []string   `json:"roles" binding:"required,gte=1,lte=3,dive,oneof=reader editor admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyPrefixURI API key URI identity binding
+type APIKeyPrefixURI struct {
+	Prefix string `uri:"prefix" binding:"required,hexadecimal,lte=16"`
+}
+
+// CreatedAPIKey newly created API key, Key is only returned once
+type CreatedAPIKey struct {
+	APIKeyDBModel
+	Key string `json:"key"`
+}
@@ -0,0 +1,57 @@
+// Package models Application structure model
+package models
+
+import (
+	"reflect"
+	"time"
+)
+
+// AuditChange value of single field before and after mutation
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEvent single recorded mutation of author or book
+type AuditEvent struct {
+	ID         int64                  `json:"id" db:"id"`
+	OccurredAt time.Time              `json:"occurred_at" db:"occurred_at"`
+	Actor      string                 `json:"actor" db:"actor"`
+	Action     string                 `json:"action" db:"action"`
+	Entity     string                 `json:"entity" db:"entity"`
+	EntityID   int                    `json:"entity_id" db:"entity_id"`
+	RequestID  *string                `json:"request_id" db:"request_id"`
+	Changes    map[string]AuditChange `json:"changes" db:"changes"`
+}
+
+// AuditFilter list audit events criteria from query params
+type AuditFilter struct {
+	Entity string `form:"entity" binding:"required_with=ID,omitempty,oneof=author book publisher genre series"`
+	ID     *int   `form:"id" binding:"omitempty,gte=1"`
+	Action string `form:"action" binding:"omitempty,oneof=create update delete restore purge"`
+	Actor  string `form:"actor" binding:"omitempty,lte=128"`
+}
+
+// FetchAuditDBModel struct to hold fetch audit events
+type FetchAuditDBModel struct {
+	Pagination
+	Data []AuditEvent `json:"data"`
+}
+
+// DiffChanges changed fields between before and after snapshot, missing
+// snapshot (record created or removed) is treated as empty
+func DiffChanges(before, after map[string]any) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+	for field, value := range before {
+		if !reflect.DeepEqual(value, after[field]) {
+			changes[field] = AuditChange{Before: value, After: after[field]}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok && value != nil {
+			changes[field] = AuditChange{Before: nil, After: value}
+		}
+	}
+
+	return changes
+}
@@ -2,293 +2,225 @@
 package models
 
 import (
-	"context"
-	"math"
+	"strconv"
+	"time"
 
-	"github.com/georgysavva/scany/v2/pgxscan"
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
-	"github.com/kasfil/bookies/pkg/database"
-	"github.com/kasfil/bookies/pkg/utilities"
+	"github.com/kasfil/bookies/pkg/validators"
 )
 
-// BookBaseModel Book base model, case for creating new record
+// BookBaseModel Book base model, case for creating new record. AuthorID is
+// the primary author, it may be left out when contributors are given. Book
+// is an edition of WorkID, new book start its own work when left out.
+// SeriesID and SeriesPosition are given together.
 type BookBaseModel struct {
-	Title    string  `json:"title" binding:"required,lte=128,gte=1"`
-	Desc     *string `json:"description"`
-	PubDate  string  `json:"pub_date" binding:"required,datetime=2006-01-02"`
-	AuthorID string  `json:"author_id" binding:"required,number"`
+	Title        string                 `json:"title" binding:"required,lte=128,gte=1"`
+	ISBN         *string                `json:"isbn" binding:"omitempty,validisbn"`
+	Desc         *string                `json:"description"`
+	PubDate      string                 `json:"pub_date" binding:"required,datetime=2006-01-02"`
+	AuthorID     string                 `json:"author_id" binding:"required_without=Contributors,omitempty,number"`
+	Contributors []ContributorBaseModel `json:"contributors" binding:"omitempty,lte=50,dive"`
+	PublisherID  *int                   `json:"publisher_id" binding:"omitempty,gte=1"`
+	Language     *string                `json:"language" binding:"omitempty,bcp47_language_tag"`
+	PageCount    *int                   `json:"page_count" binding:"omitempty,gte=1,lte=100000"`
+	Format       *string                `json:"format" binding:"omitempty,oneof=hardcover paperback ebook audio"`
+	WorkID       *int                   `json:"work_id" binding:"omitempty,gte=1"`
+	SeriesID     *int                   `json:"series_id" binding:"required_with=SeriesPosition,omitempty,gte=1"`
+	// SeriesPosition place in series reading order, may be fractional
+	SeriesPosition *float64 `json:"series_position" binding:"required_with=SeriesID,omitempty,gt=0,lt=100000"`
+	// Genres slug of every genre of the book
+	Genres []string `json:"genres" binding:"omitempty,lte=20,dive,validslug"`
+	Tags   []string `json:"tags" binding:"omitempty,lte=50,dive,gte=1,lte=32"`
 }
 
-// BookDBModel Book database model for structuring database record
-type BookDBModel struct {
-	ID      int           `json:"id" db:"id"`
-	Title   string        `json:"title" db:"title"`
-	Desc    *string       `json:"description" db:"description"`
-	PubDate *pgtype.Date  `json:"pub_date" db:"publish_date"`
-	Author  AuthorDBModel `json:"author" db:"author"`
+// ContributorBaseModel book contributor request body
+type ContributorBaseModel struct {
+	AuthorID int    `json:"author_id" binding:"required,gte=1"`
+	Role     string `json:"role" binding:"required,oneof=author co_author editor translator illustrator"`
+	Position *int   `json:"position" binding:"omitempty,gte=0,lte=1000"`
 }
 
-// FetchBookDBModel struct to hold fetch books
-type FetchBookDBModel struct {
-	Page        int           `json:"page"`
-	Limit       int           `json:"limit"`
-	Next        *int          `json:"next"`
-	Prev        *int          `json:"prev"`
-	RecordTotal int           `json:"record_total"`
-	PageTotal   int           `json:"page_total"`
-	Data        []BookDBModel `json:"data"`
+// Contributor book contributor with its role and ordering
+type Contributor struct {
+	AuthorID int    `json:"author_id"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Position int    `json:"position"`
 }
 
-// Insert add new book record
-func (m *BookDBModel) Insert(data *BookBaseModel) error {
-	query := `INSERT INTO books (title, description, publish_date, author_id)
-	VALUES (@title, @desc, @pubdate, @author_id)
-	RETURNING id, title, description, publish_date, author_id;`
-
-	// Create background context
-	ctx := context.Background()
-	// Get database connection pool
-	db, err := database.GetConnection(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Use transaction
-	tx, err := db.Conn.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	// If no error appears, commit transaction
-	defer tx.Commit(ctx)
-
-	// Init author db model to attach to the book response
-	author := new(AuthorDBModel)
-
-	err = tx.QueryRow(ctx, query, pgx.NamedArgs{
-		"title":     data.Title,
-		"desc":      data.Desc,
-		"pubdate":   data.PubDate,
-		"author_id": data.AuthorID,
-	}).Scan(&m.ID, &m.Title, &m.Desc, &m.PubDate, &author.ID)
-	if err != nil {
-		return err
-	}
-
-	// Get author detail data
-	if err := author.Detail(); err != nil {
-		return err
+// ResolveContributors get primary author and full contributor list. Primary
+// author is AuthorID when given and always listed as author, otherwise it is
+// the first contributor with author role (or the first contributor).
+// Contributor without position keeps its list order.
+func (m *BookBaseModel) ResolveContributors() (primary int, list []ContributorBaseModel) {
+	for i, contributor := range m.Contributors {
+		if contributor.Position == nil {
+			position := i
+			contributor.Position = &position
+		}
+		list = append(list, contributor)
 	}
 
-	// set author data
-	m.Author = *author
+	if m.AuthorID != "" {
+		primary, _ = strconv.Atoi(m.AuthorID)
+		for _, contributor := range list {
+			if contributor.AuthorID == primary && contributor.Role == "author" {
+				return primary, list
+			}
+		}
 
-	return nil
-}
-
-// Detail get single author by ID
-func (m *BookDBModel) Detail() error {
-	query := `SELECT
-	b.id AS id,
-	b.title AS title,
-	b.description AS description,
-	b.publish_date AS publish_date,
-	a.id AS "author.id",
-	a.name AS "author.name",
-	a.email as "author.email",
-	a.birth_date AS "author.birth_date",
-	a.bio AS "author.bio",
-	(SELECT count(id) FROM books WHERE author_id = a.id) AS "author.book_total"
-	FROM books b
-	LEFT JOIN authors a ON a.id = b.author_id
-	WHERE b.id = $1`
-
-	// Create background context
-	ctx := context.Background()
-	// Get database connection pool
-	db, err := database.GetConnection(ctx)
-	if err != nil {
-		return err
+		position := 0
+		return primary, append([]ContributorBaseModel{{AuthorID: primary, Role: "author", Position: &position}}, list...)
 	}
 
-	// run query
-	row, err := db.Conn.Query(ctx, query, m.ID)
-	if err != nil {
-		return err
+	for _, contributor := range list {
+		if contributor.Role == "author" {
+			return contributor.AuthorID, list
+		}
 	}
 
-	if err := pgxscan.ScanOne(m, row); err != nil {
-		return err
+	if len(list) == 0 {
+		return 0, nil
 	}
 
-	return nil
+	return list[0].AuthorID, list
 }
 
-// Update update book record from BookBaseModel struct
-func (m *BookDBModel) Update(data *BookBaseModel) error {
-	query := `UPDATE books
-	SET title = @title,
-		description = @desc,
-		publish_date = @pub_date,
-		author_id = @author_id
-	WHERE id = @id
-	RETURNING title, description, publish_date, author_id`
-
-	// Create background context
-	ctx := context.Background()
-	// Get database connection pool
-	db, err := database.GetConnection(ctx)
-	if err != nil {
-		return err
+// NormalizedISBN ISBN-13 form of ISBN, nil when it is not given
+func (m *BookBaseModel) NormalizedISBN() *string {
+	if m.ISBN == nil {
+		return nil
 	}
 
-	// Use transaction
-	tx, err := db.Conn.Begin(ctx)
-	if err != nil {
-		return err
+	isbn, ok := validators.NormalizeISBN(*m.ISBN)
+	if !ok {
+		return nil
 	}
-	defer tx.Commit(ctx)
-
-	// Init author db model to attach to the book response
-	author := new(AuthorDBModel)
-
-	err = tx.QueryRow(ctx, query, pgx.NamedArgs{
-		"title":     data.Title,
-		"desc":      data.Desc,
-		"pub_date":  data.PubDate,
-		"author_id": data.AuthorID,
-		"id":        m.ID,
-	}).Scan(&m.Title, &m.Desc, &m.PubDate, &author.ID)
-	if err != nil {
-		tx.Rollback(ctx)
-		return err
-	}
-
-	// Get author detail data
-	if err := author.Detail(); err != nil {
-		tx.Rollback(ctx)
-		return err
-	}
-
-	// set author data
-	m.Author = *author
-
-	return nil
+	return &isbn
 }
 
-// Delete Detele book record from database
-func (m *BookDBModel) Delete() error {
-	query := `DELETE FROM books WHERE id = $1`
-
-	// Create background context
-	ctx := context.Background()
-	// Get database connection pool
-	db, err := database.GetConnection(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Use transaction
-	tx, err := db.Conn.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Commit(ctx)
-
-	result, err := tx.Exec(ctx, query, m.ID)
-	if err != nil {
-		tx.Rollback(ctx)
-		return err
-	} else if result.RowsAffected() > 1 {
-		tx.Rollback(ctx)
-		return utilities.ErrTooManyAffectedRows
-	}
-
-	return nil
+// ISBNURI ISBN URI binding, either ISBN-10 or ISBN-13
+type ISBNURI struct {
+	ISBN string `uri:"isbn" binding:"required,validisbn"`
 }
 
-// Fetch get books database record
-func (m *FetchBookDBModel) Fetch(authorID *int) error {
-	query := `SELECT
-	b.id AS id,
-	b.title AS title,
-	b.description AS description,
-	b.publish_date AS publish_date,
-	a.id AS "author.id",
-	a.name AS "author.name",
-	a.email as "author.email",
-	a.birth_date AS "author.birth_date",
-	a.bio AS "author.bio",
-	(SELECT count(id) FROM books WHERE author_id = a.id) AS "author.book_total"
-	FROM books b
-	LEFT JOIN authors a ON a.id = b.author_id`
-
-	orderQuery := ` ORDER BY b.id DESC
-	LIMIT @limit OFFSET @offset`
+// BookKey natural key of book, its title and primary author
+type BookKey struct {
+	Title    string
+	AuthorID int
+}
 
-	// build query based on authorID existance
-	if authorID != nil {
-		query = query + " WHERE a.id = @author_id "
-	}
+// BookDBModel Book database model for structuring database record
+type BookDBModel struct {
+	ID      int           `json:"id" db:"id"`
+	Title   string        `json:"title" db:"title"`
+	ISBN    *string       `json:"isbn" db:"isbn"`
+	Desc    *string       `json:"description" db:"description"`
+	PubDate *pgtype.Date  `json:"pub_date" db:"publish_date"`
+	Author  AuthorDBModel `json:"author" db:"author"`
+	// Contributors every author of the book with its role, including the
+	// primary author
+	Contributors []Contributor `json:"contributors" db:"contributors"`
+	Publisher    *Publisher    `json:"publisher" db:"publisher"`
+	Language     *string       `json:"language" db:"language"`
+	PageCount    *int          `json:"page_count" db:"page_count"`
+	Format       *string       `json:"format" db:"format"`
+	Series       *BookSeries   `json:"series" db:"series"`
+	Cover        *BookCover    `json:"cover" db:"cover"`
+	Genres       []Genre       `json:"genres" db:"genres"`
+	Tags         []string      `json:"tags" db:"tags"`
+	// WorkID work the book is an edition of
+	WorkID    int        `json:"work_id" db:"work_id"`
+	Version   int        `json:"version" db:"version"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Rank and Highlights only filled on full-text search result
+	Rank       *float32          `json:"rank,omitempty" db:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty" db:"highlights"`
+}
 
-	query = query + orderQuery
+// BookSortFields fields accepted by books sort param
+var BookSortFields = []string{"id", "title", "publish_date", "series_position"}
+
+// BookFilter list books criteria from query params
+type BookFilter struct {
+	// AuthorID scope list to books single author contributed to in any
+	// role, set from URI
+	AuthorID     *int     `form:"-"`
+	AuthorIDs    []int    `form:"author_id[]" binding:"omitempty,lte=50,dive,gte=1"`
+	PublisherIDs []int    `form:"publisher_id[]" binding:"omitempty,lte=50,dive,gte=1"`
+	Languages    []string `form:"language[]" binding:"omitempty,lte=50,dive,bcp47_language_tag"`
+	Formats      []string `form:"format[]" binding:"omitempty,lte=4,dive,oneof=hardcover paperback ebook audio"`
+	// Genre only books of this genre slug or any of its descendants
+	Genre string   `form:"genre" binding:"omitempty,validslug"`
+	Tags  []string `form:"tag[]" binding:"omitempty,lte=20,dive,gte=1,lte=32"`
+	// Facets count matching books by tag
+	Facets bool `form:"facets"`
+	// SeriesID list books of single series, set from URI
+	SeriesID *int `form:"-"`
+	// WorkID list editions of single work
+	WorkID          *int      `form:"work_id" binding:"omitempty,gte=1"`
+	Search          string    `form:"q" binding:"omitempty,lte=256"`
+	PublishedAfter  string    `form:"published_after" binding:"omitempty,datetime=2006-01-02"`
+	PublishedBefore string    `form:"published_before" binding:"omitempty,datetime=2006-01-02"`
+	Sort            string    `form:"sort" binding:"omitempty,lte=128"`
+	SortKeys        []SortKey `form:"-"`
+	// IncludeDeleted list deleted books along with the active ones, set from
+	// include_deleted param for editors only
+	IncludeDeleted bool `form:"-"`
+	// Trashed list deleted books only, set by trash listing
+	Trashed bool `form:"-"`
+}
 
-	// Create background context
-	ctx := context.Background()
-	// Get database connection pool
-	db, err := database.GetConnection(ctx)
-	if err != nil {
-		return err
-	}
+// FetchBookDBModel struct to hold fetch books
+type FetchBookDBModel struct {
+	Pagination
+	Data []BookDBModel `json:"data"`
+	// Facets only filled when requested
+	Facets *BookFacets `json:"facets,omitempty"`
+}
 
-	countQuery := "SELECT COUNT(books.id) AS total FROM books"
-	if authorID != nil {
-		countQuery = countQuery + " JOIN authors ON authors.id = books.author_id where authors.id = @author_id"
+// Base request body representation of book record, starting point of
+// partial update
+func (m *BookDBModel) Base() BookBaseModel {
+	base := BookBaseModel{
+		Title:     m.Title,
+		ISBN:      m.ISBN,
+		Desc:      m.Desc,
+		AuthorID:  strconv.Itoa(m.Author.ID),
+		Language:  m.Language,
+		PageCount: m.PageCount,
+		Format:    m.Format,
 	}
-
-	// we need to get all total record first
-	err = db.Conn.QueryRow(ctx, countQuery, pgx.NamedArgs{"author_id": authorID}).Scan(&m.RecordTotal)
-	if err != nil {
-		return err
+	if m.Publisher != nil {
+		publisherID := m.Publisher.ID
+		base.PublisherID = &publisherID
 	}
-
-	// count total page available by divide all record total with limit, if RecordTotal % limit > 1 add one more page
-	if m.RecordTotal%m.Limit > 0 {
-		m.PageTotal = int(math.Ceil(float64(m.RecordTotal) / float64(m.Limit)))
-	} else {
-		m.PageTotal = int(m.RecordTotal / m.Limit)
+	if m.Series != nil {
+		seriesID, position := m.Series.ID, m.Series.Position
+		base.SeriesID, base.SeriesPosition = &seriesID, &position
 	}
-
-	// Check if user desired page is not higher that available page
-	// if so then set to the last page
-	if m.Page > m.PageTotal {
-		m.Page = m.PageTotal
+	for _, genre := range m.Genres {
+		base.Genres = append(base.Genres, genre.Slug)
 	}
-
-	// Count m.Prev and m.Next
-	if m.Page < 2 {
-		m.Prev = nil
-	} else {
-		prev := m.Page - 1
-		m.Prev = &prev
+	base.Tags = m.Tags
+	if m.WorkID != 0 {
+		workID := m.WorkID
+		base.WorkID = &workID
 	}
-
-	if m.Page == m.PageTotal {
-		m.Next = nil
-	} else {
-		next := m.Page + 1
-		m.Next = &next
+	if m.PubDate != nil && m.PubDate.Valid {
+		base.PubDate = m.PubDate.Time.Format(time.DateOnly)
 	}
 
-	err = pgxscan.Select(ctx, db.Conn, &m.Data, query, pgx.NamedArgs{
-		"limit":     m.Limit,
-		"offset":    m.Limit * (m.Page - 1),
-		"author_id": authorID,
-	})
-	if err != nil {
-		return err
+	for _, contributor := range m.Contributors {
+		position := contributor.Position
+		base.Contributors = append(base.Contributors, ContributorBaseModel{
+			AuthorID: contributor.AuthorID,
+			Role:     contributor.Role,
+			Position: &position,
+		})
 	}
 
-	return nil
+	return base
 }
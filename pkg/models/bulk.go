@@ -0,0 +1,50 @@
+// Package models Application structure model
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// Bulk write modes, all-or-nothing roll back every item when one fails and
+// best-effort keep every item which succeed
+const (
+	BulkAtomic     = "atomic"
+	BulkBestEffort = "best_effort"
+)
+
+// BulkRequest bulk write request body, defaults to all-or-nothing mode
+type BulkRequest struct {
+	Mode  string     `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Items []BulkItem `json:"items" binding:"required,gte=1,lte=1000,dive"`
+}
+
+// BulkItem single bulk operation, defaults to create. Data is the same body
+// as single create or full update, update is only applied on Version when
+// given.
+type BulkItem struct {
+	Op      string          `json:"op" binding:"omitempty,oneof=create update delete"`
+	ID      int             `json:"id" binding:"omitempty,gte=1"`
+	Version int             `json:"version" binding:"omitempty,gte=1"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// BulkResult outcome of single bulk item, status is the one single request
+// would have got
+type BulkResult struct {
+	Index   int                            `json:"index"`
+	Status  int                            `json:"status"`
+	ID      int                            `json:"id,omitempty"`
+	Version int                            `json:"version,omitempty"`
+	Msg     string                         `json:"msg,omitempty"`
+	Errors  []utilities.ValidationErrorMsg `json:"errors,omitempty"`
+}
+
+// BulkResponse outcome of every bulk item in request order
+type BulkResponse struct {
+	Mode      string       `json:"mode"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
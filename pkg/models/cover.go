@@ -0,0 +1,17 @@
+// Package models Application structure model
+package models
+
+// BookCover cover image of book
+type BookCover struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// CoverObjects storage keys of book cover image and its thumbnail along
+// with their public URL
+type CoverObjects struct {
+	Key          string
+	ThumbnailKey string
+	URL          string
+	ThumbnailURL string
+}
@@ -0,0 +1,62 @@
+// Package models Application structure model
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// GenreBaseModel genre request body, genre without parent is a root genre
+type GenreBaseModel struct {
+	Slug     string `json:"slug" binding:"required,lte=64,validslug"`
+	Name     string `json:"name" binding:"required,gte=1,lte=64"`
+	ParentID *int   `json:"parent_id" binding:"omitempty,gte=1"`
+}
+
+// GenreDBModel genre database record, path is slug of every ancestor from
+// the root down to the genre itself
+type GenreDBModel struct {
+	ID        int       `json:"id" db:"id"`
+	Slug      string    `json:"slug" db:"slug"`
+	Name      string    `json:"name" db:"name"`
+	ParentID  *int      `json:"parent_id" db:"parent_id"`
+	Path      []string  `json:"path" db:"path"`
+	BookTotal uint      `json:"book_total" db:"book_total"`
+	Version   int       `json:"version" db:"version"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Genre genre shown within its books
+type Genre struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// TagCount number of matching books having tag
+type TagCount struct {
+	Tag   string `json:"tag" db:"tag"`
+	Count int    `json:"count" db:"count"`
+}
+
+// BookFacets counts of matching books by tag, most used first
+type BookFacets struct {
+	Tags []TagCount `json:"tags"`
+}
+
+// NormalizeTags trim and lower case tags, empty and repeated tags are
+// dropped
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
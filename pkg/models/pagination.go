@@ -0,0 +1,165 @@
+// Package models Application structure model
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrInvalidCursor cursor is malformed or created for different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Pagination page envelope shared by every list response. When Cursor is
+// set the list use keyset pagination, page related fields are left empty and
+// NextCursor point to the following page, it is omitted on the last page.
+type Pagination struct {
+	Page        int     `json:"page"`
+	Limit       int     `json:"limit"`
+	Next        *int    `json:"next"`
+	Prev        *int    `json:"prev"`
+	RecordTotal int     `json:"record_total"`
+	PageTotal   int     `json:"page_total"`
+	Cursor      *string `json:"-"`
+	NextCursor  *string `json:"next_cursor,omitempty"`
+}
+
+// Paginate fill page envelope from total record found
+func (p *Pagination) Paginate(recordTotal int) {
+	p.RecordTotal = recordTotal
+
+	// count total page available by divide all record total with limit, if RecordTotal % limit > 1 add one more page
+	p.PageTotal = int(math.Ceil(float64(p.RecordTotal) / float64(p.Limit)))
+
+	// Check if user desired page is not higher that available page
+	// if so then set to the last page
+	if p.Page > p.PageTotal && p.PageTotal > 0 {
+		p.Page = p.PageTotal
+	}
+
+	// Count p.Prev and p.Next
+	if p.Page < 2 {
+		p.Prev = nil
+	} else {
+		prev := p.Page - 1
+		p.Prev = &prev
+	}
+
+	if p.Page >= p.PageTotal {
+		p.Next = nil
+	} else {
+		next := p.Page + 1
+		p.Next = &next
+	}
+}
+
+// Offset number of record skipped before current page
+func (p *Pagination) Offset() int {
+	return p.Limit * (p.Page - 1)
+}
+
+// cursorPayload keyset position encoded into opaque cursor
+type cursorPayload struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// EncodeCursor create opaque cursor pointing after the row holding values,
+// values follow keys order
+func EncodeCursor(keys []SortKey, values []string) string {
+	raw, _ := json.Marshal(cursorPayload{Sort: SortSpec(keys), Values: values})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor get keyset values from cursor, cursor must be created with the
+// same sort keys
+func DecodeCursor(cursor string, keys []SortKey) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if payload.Sort != SortSpec(keys) || len(payload.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	return payload.Values, nil
+}
+
+// SortSpec canonical text form of sort keys, e.g. "title:asc,id:desc"
+func SortSpec(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Desc {
+			parts[i] = key.Field + ":desc"
+		} else {
+			parts[i] = key.Field + ":asc"
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// SortKey single sort criteria of list endpoint
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parse sort param such as "title,-publish_date" or
+// "title:asc,publish_date:desc", only allowed fields are accepted
+func ParseSort(raw string, allowed []string) ([]SortKey, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var keys []SortKey
+	seen := map[string]bool{}
+	for _, part := range strings.Split(raw, ",") {
+		key := SortKey{Field: strings.TrimSpace(part)}
+
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field, key.Desc = key.Field[1:], true
+		} else if field, direction, ok := strings.Cut(key.Field, ":"); ok {
+			switch direction {
+			case "asc":
+			case "desc":
+				key.Desc = true
+			default:
+				return nil, fmt.Errorf("sort direction of %s should be asc or desc", field)
+			}
+			key.Field = field
+		}
+
+		if !contains(allowed, key.Field) {
+			return nil, fmt.Errorf("unknown sort field %q, allowed fields are %s", key.Field, strings.Join(allowed, ", "))
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("duplicate sort field %s", key.Field)
+		}
+		seen[key.Field] = true
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// contains tells whether value is part of list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
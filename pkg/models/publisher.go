@@ -0,0 +1,43 @@
+// Package models Application structure model
+package models
+
+import "time"
+
+// PublisherBaseModel publisher request body
+type PublisherBaseModel struct {
+	Name    string  `json:"name" binding:"required,gte=1,lte=128"`
+	Website *string `json:"website" binding:"omitempty,url,lte=256"`
+}
+
+// PublisherDBModel publisher database record
+type PublisherDBModel struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Website   *string   `json:"website" db:"website"`
+	BookTotal uint      `json:"book_total" db:"book_total"`
+	Version   int       `json:"version" db:"version"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Publisher publisher shown within its books
+type Publisher struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// PublisherSortFields fields accepted by publishers sort param
+var PublisherSortFields = []string{"id", "name", "book_total"}
+
+// PublisherFilter list publishers criteria from query params
+type PublisherFilter struct {
+	// Search match publishers whose name contains it, case insensitive
+	Search   string    `form:"q" binding:"omitempty,lte=128"`
+	Sort     string    `form:"sort" binding:"omitempty,lte=128"`
+	SortKeys []SortKey `form:"-"`
+}
+
+// FetchPublisherDBModel page of publishers
+type FetchPublisherDBModel struct {
+	Pagination
+	Data []PublisherDBModel `json:"data"`
+}
@@ -0,0 +1,45 @@
+// Package models Application structure model
+package models
+
+import "time"
+
+// SeriesBaseModel series request body
+type SeriesBaseModel struct {
+	Name        string  `json:"name" binding:"required,gte=1,lte=128"`
+	Description *string `json:"description" binding:"omitempty,lte=2000"`
+}
+
+// SeriesDBModel series database record
+type SeriesDBModel struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description" db:"description"`
+	BookTotal   uint      `json:"book_total" db:"book_total"`
+	Version     int       `json:"version" db:"version"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BookSeries series shown within its books along with book position in
+// reading order
+type BookSeries struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"`
+}
+
+// SeriesSortFields fields accepted by series sort param
+var SeriesSortFields = []string{"id", "name", "book_total"}
+
+// SeriesFilter list series criteria from query params
+type SeriesFilter struct {
+	// Search match series whose name contains it, case insensitive
+	Search   string    `form:"q" binding:"omitempty,lte=128"`
+	Sort     string    `form:"sort" binding:"omitempty,lte=128"`
+	SortKeys []SortKey `form:"-"`
+}
+
+// FetchSeriesDBModel page of series
+type FetchSeriesDBModel struct {
+	Pagination
+	Data []SeriesDBModel `json:"data"`
+}
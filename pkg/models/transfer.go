@@ -0,0 +1,174 @@
+// Package models Application structure model
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export and import formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// AuthorCSVColumns columns of author CSV export
+var AuthorCSVColumns = []string{"id", "name", "email", "birth_date", "bio", "book_total", "version", "updated_at"}
+
+// CSVRecord author as CSV row in AuthorCSVColumns order
+func (m *AuthorDBModel) CSVRecord() []string {
+	base := m.Base()
+	return []string{
+		strconv.Itoa(m.ID),
+		m.Name,
+		m.Email,
+		optional(base.BirthDate),
+		optional(m.Bio),
+		strconv.FormatUint(uint64(m.BookTotal), 10),
+		strconv.Itoa(m.Version),
+		m.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// BookCSVColumns columns of book CSV export, contributors are written as
+// "author_id:role" separated by ";" in position order, genre slugs and tags
+// are separated by ";" as well
+var BookCSVColumns = []string{
+	"id", "title", "isbn", "description", "pub_date", "author_id", "author_email", "contributors",
+	"publisher_id", "language", "page_count", "format", "work_id", "series_id", "series_position",
+	"genres", "tags", "version", "updated_at",
+}
+
+// CSVRecord book as CSV row in BookCSVColumns order
+func (m *BookDBModel) CSVRecord() []string {
+	base := m.Base()
+	return []string{
+		strconv.Itoa(m.ID),
+		m.Title,
+		optional(m.ISBN),
+		optional(m.Desc),
+		base.PubDate,
+		base.AuthorID,
+		m.Author.Email,
+		FormatContributors(base.Contributors),
+		optionalInt(base.PublisherID),
+		optional(m.Language),
+		optionalInt(m.PageCount),
+		optional(m.Format),
+		strconv.Itoa(m.WorkID),
+		optionalInt(base.SeriesID),
+		optionalFloat(base.SeriesPosition),
+		strings.Join(base.Genres, ";"),
+		strings.Join(base.Tags, ";"),
+		strconv.Itoa(m.Version),
+		m.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// FormatContributors contributors CSV cell, "author_id:role" separated by ";"
+func FormatContributors(contributors []ContributorBaseModel) string {
+	parts := make([]string, len(contributors))
+	for i, contributor := range contributors {
+		parts[i] = strconv.Itoa(contributor.AuthorID) + ":" + contributor.Role
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// ParseContributors read contributors CSV cell, position follow cell order
+func ParseContributors(cell string) ([]ContributorBaseModel, error) {
+	var contributors []ContributorBaseModel
+	for i, part := range strings.Split(cell, ";") {
+		id, role, ok := strings.Cut(strings.TrimSpace(part), ":")
+		authorID, err := strconv.Atoi(id)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("contributor %q should be author_id:role", part)
+		}
+
+		position := i
+		contributors = append(contributors, ContributorBaseModel{AuthorID: authorID, Role: role, Position: &position})
+	}
+
+	return contributors, nil
+}
+
+// ParseList read ";" separated CSV cell, blank items are dropped
+func ParseList(cell string) []string {
+	items := []string{}
+	for _, item := range strings.Split(cell, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// optional value of nullable text, empty when null
+func optional(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+// optionalInt text of nullable number, empty when null
+func optionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*value)
+}
+
+// optionalFloat text of nullable decimal, empty when null
+func optionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// ImportParams import query params. Mapping read from map[field]=column
+// params name the source column of each field, columns named after fields
+// are used as is.
+type ImportParams struct {
+	Entity  string            `form:"entity" binding:"required,oneof=authors books"`
+	Format  string            `form:"format" binding:"omitempty,oneof=csv ndjson"`
+	Mode    string            `form:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	DryRun  bool              `form:"dry_run"`
+	Mapping map[string]string `form:"-"`
+}
+
+// Import row actions
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	ImportInvalid   = "invalid"
+)
+
+// ImportRow outcome of single imported row, line is the source line number
+type ImportRow struct {
+	Line   int    `json:"line"`
+	Action string `json:"action"`
+	BulkResult
+}
+
+// ImportReport outcome of every imported row in source order, dry run
+// report what would be written without writing anything
+type ImportReport struct {
+	Entity    string      `json:"entity"`
+	Format    string      `json:"format"`
+	Mode      string      `json:"mode"`
+	DryRun    bool        `json:"dry_run"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Unchanged int         `json:"unchanged"`
+	Failed    int         `json:"failed"`
+	Rows      []ImportRow `json:"rows"`
+}
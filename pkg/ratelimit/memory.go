@@ -0,0 +1,73 @@
+// Package ratelimit Token bucket rate limiting per client
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket single client token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// refill time from empty to full under the bucket limit
+	refill time.Duration
+}
+
+// MemoryStore in process Store, buckets are not shared between instances
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// sweepInterval how often full buckets are dropped
+const sweepInterval = time.Minute
+
+// NewMemoryStore create empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+// Take take single token from bucket of key
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.refill = seconds(float64(limit.Burst) / limit.Rate)
+
+	// refill since last take
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	s.sweep(now)
+	return newResult(allowed, b.tokens, limit), nil
+}
+
+// sweep drop buckets which are full again, they are the same as a new one
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.refill {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package ratelimit Token bucket rate limiting per client
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/auth"
+)
+
+// AuthGroup group of authentication attempts, its bucket is always keyed by
+// client IP
+const AuthGroup = "auth"
+
+// Limiter rate limit requests of every route group by client
+type Limiter struct {
+	store    Store
+	fallback Limit
+	limits   map[string]Limit
+}
+
+// NewLimiter create limiter applying fallback limit to group without its
+// own limit
+func NewLimiter(store Store, fallback Limit) *Limiter {
+	return &Limiter{store: store, fallback: fallback, limits: map[string]Limit{}}
+}
+
+// NewLimiterFromEnv create limiter from RATE_LIMIT env and RATE_LIMIT_<GROUP>
+// env of every group and of AuthGroup
+func NewLimiterFromEnv(store Store, groups ...string) *Limiter {
+	l := NewLimiter(store, EnvLimit("RATE_LIMIT", Limit{Rate: 10, Burst: 20}))
+	for _, group := range groups {
+		l.SetLimit(group, EnvLimit("RATE_LIMIT_"+strings.ToUpper(group), l.fallback))
+	}
+	l.SetLimit(AuthGroup, EnvLimit("RATE_LIMIT_AUTH", l.fallback))
+
+	return l
+}
+
+// SetLimit set limit of route group
+func (l *Limiter) SetLimit(group string, limit Limit) {
+	l.limits[group] = limit
+}
+
+// Limit get limit of route group
+func (l *Limiter) Limit(group string) Limit {
+	if limit, ok := l.limits[group]; ok {
+		return limit
+	}
+
+	return l.fallback
+}
+
+// Middleware throttle requests of route group per client. Client is the
+// authenticated principal or the client IP for anonymous request, each has
+// its own bucket per group. Store failure let the request through.
+func (l *Limiter) Middleware(group string) gin.HandlerFunc {
+	limit := l.Limit(group)
+	return func(c *gin.Context) {
+		if l.throttle(c, group+":"+clientKey(c), limit) {
+			c.Next()
+		}
+	}
+}
+
+// Authentication throttle requests carrying credentials per client IP, it
+// runs before authentication so invalid credentials can not be tried without
+// limit. Request without credentials is left to the route group limit.
+func (l *Limiter) Authentication() gin.HandlerFunc {
+	limit := l.Limit(AuthGroup)
+	return func(c *gin.Context) {
+		if !auth.HasCredentials(c) || l.throttle(c, AuthGroup+":ip:"+c.ClientIP(), limit) {
+			c.Next()
+		}
+	}
+}
+
+// throttle take token from bucket of key, false when request is aborted for
+// running out of tokens
+func (l *Limiter) throttle(c *gin.Context, key string, limit Limit) bool {
+	if !limit.Enabled() {
+		return true
+	}
+
+	result, err := l.store.Take(c.Request.Context(), key, limit, time.Now())
+	if err != nil {
+		log.Println("rate limit store failed", err)
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", ceilSeconds(result.Reset))
+
+	if !result.Allowed {
+		c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"msg": "too many requests, please slow down"})
+		return false
+	}
+
+	return true
+}
+
+// clientKey bucket key of request client
+func clientKey(c *gin.Context) string {
+	if principal, ok := auth.PrincipalFrom(c); ok {
+		return principal.Actor()
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds duration as whole seconds rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,102 @@
+// Package ratelimit Token bucket rate limiting per client
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit token bucket size and refill rate
+type Limit struct {
+	// Rate tokens refilled per second, zero or negative disables the limit
+	Rate float64
+	// Burst bucket capacity, maximum requests allowed at once
+	Burst int
+}
+
+// Enabled tells whether limit throttles anything
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Result outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter time until next token is available, zero when allowed
+	RetryAfter time.Duration
+	// Reset time until bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps token buckets by key, implementation must be safe for
+// concurrent use
+type Store interface {
+	// Take take single token from bucket of key
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// newResult result of bucket holding tokens after token is taken or denied
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	result := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+
+	return result
+}
+
+// seconds convert float seconds into duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ParseLimit parse limit in "RATE,BURST" form, RATE is requests per second
+// (e.g. "10,20" or "0.5,5"), "0" disables the limit
+func ParseLimit(value string) (Limit, error) {
+	rawRate, rawBurst, ok := strings.Cut(value, ",")
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rawRate), 64)
+	if err != nil || rate < 0 {
+		return Limit{}, fmt.Errorf("invalid rate %q", rawRate)
+	}
+	if rate == 0 && !ok {
+		return Limit{}, nil
+	}
+
+	burst := int(math.Ceil(rate))
+	if ok {
+		burst, err = strconv.Atoi(strings.TrimSpace(rawBurst))
+		if err != nil || burst < 0 {
+			return Limit{}, fmt.Errorf("invalid burst %q", rawBurst)
+		}
+	}
+
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// EnvLimit get environment variable parsed by ParseLimit, fallback is
+// returned when the variable is not set or invalid
+func EnvLimit(key string, fallback Limit) Limit {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	limit, err := ParseLimit(value)
+	if err != nil {
+		log.Printf("invalid %s value %q, using %v", key, value, fallback)
+		return fallback
+	}
+
+	return limit
+}
@@ -0,0 +1,77 @@
+// Package ratelimit Token bucket rate limiting per client
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RedisClient subset of Redis client used by RedisStore, any client which
+// can run EVAL (Redis, Valkey, KeyDB, ...) can be adapted to it
+type RedisClient interface {
+	// Eval run Lua script and return its reply
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// takeScript refill and take token atomically. Tokens are returned as
+// string since Lua numbers are truncated to integer in Redis reply.
+const takeScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, tostring(tokens)}
+`
+
+// RedisStore Store shared between instances through Redis compatible server
+type RedisStore struct {
+	// Prefix prepended to every bucket key
+	Prefix string
+
+	client RedisClient
+}
+
+// NewRedisStore create store on top of Redis client
+func NewRedisStore(client RedisClient) *RedisStore {
+	return &RedisStore{Prefix: "ratelimit:", client: client}
+}
+
+// Take take single token from bucket of key
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	reply, err := s.client.Eval(ctx, takeScript, []string{s.Prefix + key},
+		limit.Rate, limit.Burst, now.UnixMilli())
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+
+	raw, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(tokens) {
+		return Result{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+
+	return newResult(allowed == 1, tokens, limit), nil
+}
@@ -0,0 +1,22 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"github.com/kasfil/bookies/pkg/database"
+)
+
+// NewPgxRepositories create every repository backed by PostgreSQL database pool
+func NewPgxRepositories(db *database.DbPool) *Repositories {
+	return &Repositories{
+		Author:    NewPgxAuthorRepository(db),
+		Book:      NewPgxBookRepository(db),
+		Audit:     NewPgxAuditRepository(db),
+		APIKey:    NewPgxAPIKeyRepository(db),
+		Publisher: NewPgxPublisherRepository(db),
+		Genre:     NewPgxGenreRepository(db),
+		Series:    NewPgxSeriesRepository(db),
+	}
+}
+
+// headlineOptions ts_headline options used to highlight search result
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10"
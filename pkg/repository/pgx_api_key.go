@@ -0,0 +1,68 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxAPIKeyRepository PostgreSQL backed APIKeyRepository
+type PgxAPIKeyRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxAPIKeyRepository create API key repository on top of database pool
+func NewPgxAPIKeyRepository(db *database.DbPool) *PgxAPIKeyRepository {
+	return &PgxAPIKeyRepository{db: db}
+}
+
+// apiKeyColumns every api_keys column
+const apiKeyColumns = "id, name, prefix, key_hash, roles, created_at, expires_at, last_used_at, revoked_at"
+
+// Insert add new API key record
+func (r *PgxAPIKeyRepository) Insert(ctx context.Context, m *models.APIKeyDBModel) error {
+	query := `INSERT INTO api_keys (name, prefix, key_hash, roles, expires_at)
+	VALUES (@name, @prefix, @key_hash, @roles, @expires_at)
+	RETURNING id, created_at`
+
+	return r.db.Conn.QueryRow(ctx, query, pgx.NamedArgs{
+		"name":       m.Name,
+		"prefix":     m.Prefix,
+		"key_hash":   m.KeyHash,
+		"roles":      m.Roles,
+		"expires_at": m.ExpiresAt,
+	}).Scan(&m.ID, &m.CreatedAt)
+}
+
+// FindByPrefix get API key by its prefix, including revoked and expired key
+func (r *PgxAPIKeyRepository) FindByPrefix(ctx context.Context, m *models.APIKeyDBModel) error {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix = $1"
+
+	return pgxscan.Get(ctx, r.db.Conn, m, query, m.Prefix)
+}
+
+// Touch record key usage, updated at most once a minute to spare writes
+func (r *PgxAPIKeyRepository) Touch(ctx context.Context, m *models.APIKeyDBModel) error {
+	_, err := r.db.Conn.Exec(ctx, `UPDATE api_keys SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, m.ID)
+	return err
+}
+
+// Revoke revoke API key by its prefix
+func (r *PgxAPIKeyRepository) Revoke(ctx context.Context, m *models.APIKeyDBModel) error {
+	return r.db.Conn.QueryRow(ctx, `UPDATE api_keys SET revoked_at = now()
+	WHERE prefix = $1 AND revoked_at IS NULL
+	RETURNING id, revoked_at`, m.Prefix).Scan(&m.ID, &m.RevokedAt)
+}
+
+// List get every API key, newest first
+func (r *PgxAPIKeyRepository) List(ctx context.Context) ([]models.APIKeyDBModel, error) {
+	var keys []models.APIKeyDBModel
+	err := pgxscan.Select(ctx, r.db.Conn, &keys, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id DESC")
+	return keys, err
+}
@@ -0,0 +1,256 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kasfil/bookies/pkg/audit"
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxAuditRepository PostgreSQL backed AuditRepository
+type PgxAuditRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxAuditRepository create audit repository on top of database pool
+func NewPgxAuditRepository(db *database.DbPool) *PgxAuditRepository {
+	return &PgxAuditRepository{db: db}
+}
+
+// auditEntity audited entity name and query to get its record as JSON
+// object, the query lock the record until transaction ends. Search vector and
+// updated_at (touched by related records) are left out of the snapshot.
+type auditEntity struct {
+	name     string
+	snapshot string
+}
+
+var (
+	authorAudit = auditEntity{
+		name:     "author",
+		snapshot: `SELECT to_jsonb(a) - 'search' - 'updated_at' FROM authors a WHERE a.id = $1 FOR UPDATE`,
+	}
+	bookAudit = auditEntity{
+		name: "book",
+		snapshot: `SELECT to_jsonb(b) - 'search' - 'updated_at' || jsonb_build_object('contributors', COALESCE((
+		SELECT jsonb_agg(jsonb_build_object(
+			'author_id', bc.author_id,
+			'role', bc.role,
+			'position', bc.position
+		) ORDER BY bc.position, bc.author_id)
+		FROM book_contributors bc
+		WHERE bc.book_id = b.id), '[]'::jsonb), 'genres', COALESCE((
+		SELECT jsonb_agg(bg.genre_id ORDER BY bg.genre_id)
+		FROM book_genres bg
+		WHERE bg.book_id = b.id), '[]'::jsonb), 'tags', COALESCE((
+		SELECT jsonb_agg(bt.tag ORDER BY bt.tag)
+		FROM book_tags bt
+		WHERE bt.book_id = b.id), '[]'::jsonb))
+	FROM books b WHERE b.id = $1 FOR UPDATE`,
+	}
+	publisherAudit = auditEntity{
+		name:     "publisher",
+		snapshot: `SELECT to_jsonb(p) - 'updated_at' FROM publishers p WHERE p.id = $1 FOR UPDATE`,
+	}
+	seriesAudit = auditEntity{
+		name:     "series",
+		snapshot: `SELECT to_jsonb(s) - 'updated_at' FROM series s WHERE s.id = $1 FOR UPDATE`,
+	}
+	genreAudit = auditEntity{
+		name:     "genre",
+		snapshot: `SELECT to_jsonb(g) - 'updated_at' FROM genres g WHERE g.id = $1 FOR UPDATE`,
+	}
+)
+
+// snapshot get current record of entity within tx, nil when it does not exist
+func snapshot(ctx context.Context, tx pgx.Tx, entity auditEntity, id int) (map[string]any, error) {
+	var record map[string]any
+	err := tx.QueryRow(ctx, entity.snapshot, id).Scan(&record)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+
+	return record, err
+}
+
+// insertEvent audit event insert statement
+const insertEvent = `INSERT INTO audit_events (actor, action, entity, entity_id, request_id, changes)
+	VALUES (@actor, @action, @entity, @entity_id, @request_id, @changes)`
+
+// eventArgs audit event insert arguments, actor and request ID come from ctx
+func eventArgs(ctx context.Context, action string, entity auditEntity, id int, changes map[string]models.AuditChange) pgx.NamedArgs {
+	return pgx.NamedArgs{
+		"actor":      audit.ActorFrom(ctx),
+		"action":     action,
+		"entity":     entity.name,
+		"entity_id":  id,
+		"request_id": audit.RequestIDFrom(ctx),
+		"changes":    changes,
+	}
+}
+
+// recordEvent write audit event within tx, actor and request ID come from ctx
+func recordEvent(ctx context.Context, tx pgx.Tx, action string, entity auditEntity, id int, changes map[string]models.AuditChange) error {
+	_, err := tx.Exec(ctx, insertEvent, eventArgs(ctx, action, entity, id, changes))
+	return err
+}
+
+// recordDiff write audit event of difference between before snapshot and
+// current record, changes of the record is returned
+func recordDiff(ctx context.Context, tx pgx.Tx, action string, entity auditEntity, id int, before map[string]any) (map[string]models.AuditChange, error) {
+	after, err := snapshot(ctx, tx, entity, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := models.DiffChanges(before, after)
+	return changes, recordEvent(ctx, tx, action, entity, id, changes)
+}
+
+// recordCreates write create audit event of every new record within tx,
+// snapshots and events are each sent in a single batch
+func recordCreates(ctx context.Context, tx pgx.Tx, entity auditEntity, ids []int) error {
+	records := make([]map[string]any, len(ids))
+	batch := new(pgx.Batch)
+	for i, id := range ids {
+		batch.Queue(entity.snapshot, id).QueryRow(func(row pgx.Row) error {
+			return row.Scan(&records[i])
+		})
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	batch = new(pgx.Batch)
+	for i, id := range ids {
+		batch.Queue(insertEvent, eventArgs(ctx, "create", entity, id, models.DiffChanges(nil, records[i])))
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// auditSortColumns audit events are always listed newest first
+var auditSortColumns = map[string]sortColumn{
+	"id": {expr: "e.id", cast: "bigint"},
+}
+
+// Fetch get audit events, newest first
+func (r *PgxAuditRepository) Fetch(ctx context.Context, m *models.FetchAuditDBModel, filter *models.AuditFilter) error {
+	q := newSelect("audit_events e",
+		"e.id AS id",
+		"e.occurred_at AS occurred_at",
+		"e.actor AS actor",
+		"e.action AS action",
+		"e.entity AS entity",
+		"e.entity_id AS entity_id",
+		"e.request_id AS request_id",
+		"e.changes AS changes",
+	)
+
+	if filter.Entity != "" {
+		q.Where("e.entity = @entity").Arg("entity", filter.Entity)
+	}
+	if filter.ID != nil {
+		q.Where("e.entity_id = @entity_id").Arg("entity_id", *filter.ID)
+	}
+	if filter.Action != "" {
+		q.Where("e.action = @action").Arg("action", filter.Action)
+	}
+	if filter.Actor != "" {
+		q.Where("e.actor = @actor").Arg("actor", filter.Actor)
+	}
+
+	keys := defaultSort(nil, false)
+	if err := q.Sort(keys, auditSortColumns); err != nil {
+		return err
+	}
+
+	var query string
+	if m.Cursor != nil {
+		// keyset mode, continue after cursor position without counting
+		if *m.Cursor != "" {
+			values, err := models.DecodeCursor(*m.Cursor, keys)
+			if err != nil {
+				return err
+			}
+			if err := q.Keyset(keys, auditSortColumns, values); err != nil {
+				return err
+			}
+		}
+
+		// one extra row tells whether next page exists
+		query = q.PagedSQL(m.Limit+1, 0)
+	} else {
+		// we need to get all total record first
+		var total int
+		if err := r.db.Conn.QueryRow(ctx, q.CountSQL(), q.Args()).Scan(&total); err != nil {
+			return err
+		}
+		m.Paginate(total)
+
+		query = q.PagedSQL(m.Limit, m.Offset())
+	}
+
+	err := pgxscan.Select(ctx, r.db.Conn, &m.Data, query, q.Args())
+	if err != nil {
+		return err
+	}
+
+	if m.Cursor != nil && len(m.Data) > m.Limit {
+		m.Data = m.Data[:m.Limit]
+		next := models.EncodeCursor(keys, []string{strconv.FormatInt(m.Data[m.Limit-1].ID, 10)})
+		m.NextCursor = &next
+	}
+
+	return nil
+}
+
+// recordEach write the same change audit event for every record id in rows
+func recordEach(ctx context.Context, tx pgx.Tx, rows pgx.Rows, action string, entity auditEntity, change models.AuditChange) error {
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := recordEvent(ctx, tx, action, entity, id, map[string]models.AuditChange{"deleted_at": change}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execer run statement on pool or within transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// purge permanently remove records of table deleted before given time and
+// record purge audit event of each in the same statement
+func purge(ctx context.Context, db execer, table string, entity auditEntity, before time.Time) (int64, error) {
+	query := `WITH purged AS (DELETE FROM ` + table + ` WHERE deleted_at < @before RETURNING id)
+	INSERT INTO audit_events (actor, action, entity, entity_id, request_id)
+	SELECT @actor, 'purge', @entity, id, @request_id FROM purged`
+
+	result, err := db.Exec(ctx, query, pgx.NamedArgs{
+		"before":     before,
+		"actor":      audit.ActorFrom(ctx),
+		"entity":     entity.name,
+		"request_id": audit.RequestIDFrom(ctx),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,563 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxAuthorRepository PostgreSQL backed AuthorRepository
+type PgxAuthorRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxAuthorRepository create author repository on top of database pool
+func NewPgxAuthorRepository(db *database.DbPool) *PgxAuthorRepository {
+	return &PgxAuthorRepository{db: db}
+}
+
+// insertAuthor author insert statement
+const insertAuthor = `INSERT INTO authors (name, email, birth_date, bio)
+	VALUES (@name, @email, @birth_date, @bio)
+	RETURNING id, name, email, birth_date, bio, version, updated_at`
+
+// Insert add new author record to the database
+func (r *PgxAuthorRepository) Insert(ctx context.Context, m *models.AuthorDBModel, author *models.AuthorBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := insertAuthors(ctx, tx, []AuthorBulkOp{{Action: BulkCreate, Model: m, Data: author}}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertAuthors add author record of every op within tx, inserts and audit
+// events are sent in batches
+func insertAuthors(ctx context.Context, tx pgx.Tx, ops []AuthorBulkOp) error {
+	batch := new(itemBatch)
+	for i, op := range ops {
+		m := op.Model
+		batch.Queue(i, func(row pgx.Row) error {
+			return row.Scan(&m.ID, &m.Name, &m.Email, &m.BirthDate, &m.Bio, &m.Version, &m.UpdatedAt)
+		}, insertAuthor, pgx.NamedArgs{
+			"name":       op.Data.Name,
+			"email":      op.Data.Email,
+			"birth_date": op.Data.BirthDate,
+			"bio":        op.Data.Bio,
+		})
+	}
+	if err := batch.Send(ctx, tx); err != nil {
+		return err
+	}
+
+	ids := make([]int, len(ops))
+	for i, op := range ops {
+		ids[i] = op.Model.ID
+	}
+
+	return recordCreates(ctx, tx, authorAudit, ids)
+}
+
+// authorFrom authors table joined with contributions on books which are not
+// deleted, so book_total only count active books
+const authorFrom = `authors a
+	LEFT JOIN (book_contributors bc JOIN books cb ON cb.id = bc.book_id AND cb.deleted_at IS NULL)
+	ON a.id = bc.author_id`
+
+// Detail get single author by ID, deleted author is not found
+func (r *PgxAuthorRepository) Detail(ctx context.Context, m *models.AuthorDBModel) error {
+	query := `SELECT 
+	a.id AS id,
+	a.name AS name,
+	a.email as email,
+	a.birth_date AS birth_date,
+	a.bio AS bio,
+	a.version AS version,
+	a.updated_at AS updated_at,
+	COUNT(DISTINCT bc.book_id) AS book_total
+	FROM ` + authorFrom + `
+	WHERE a.id = @id AND a.deleted_at IS NULL
+	GROUP BY a.id`
+
+	// run query
+	row, err := r.db.Conn.Query(ctx, query, pgx.NamedArgs{
+		"id": m.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	*m, err = pgx.CollectExactlyOneRow(row, pgx.RowToStructByNameLax[models.AuthorDBModel])
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stamp get version and last modification time of author by ID, deleted
+// author is not found
+func (r *PgxAuthorRepository) Stamp(ctx context.Context, m *models.AuthorDBModel) error {
+	return r.db.Conn.QueryRow(ctx, `SELECT version, updated_at FROM authors
+	WHERE id = $1 AND deleted_at IS NULL`, m.ID).Scan(&m.Version, &m.UpdatedAt)
+}
+
+// FindByEmail get active authors having any of emails
+func (r *PgxAuthorRepository) FindByEmail(ctx context.Context, emails []string) ([]models.AuthorDBModel, error) {
+	var authors []models.AuthorDBModel
+	err := pgxscan.Select(ctx, r.db.Conn, &authors, `SELECT id, name, email, birth_date, bio, version, updated_at
+	FROM authors
+	WHERE email = ANY($1) AND deleted_at IS NULL`, emails)
+
+	return authors, err
+}
+
+// authorFields author fields which can be updated mapped to their column
+var authorFields = map[string]string{
+	"name":       "name",
+	"email":      "email",
+	"birth_date": "birth_date",
+	"bio":        "bio",
+}
+
+// authorUpdateFields fields written by full update
+var authorUpdateFields = []string{"name", "email", "birth_date", "bio"}
+
+// Update update AuthorDBModel from AuthorBaseModel struct, only when record
+// is still at m.Version otherwise ErrVersionConflict is returned
+func (r *PgxAuthorRepository) Update(ctx context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel) error {
+	return r.Patch(ctx, m, data, authorUpdateFields)
+}
+
+// Patch update only given fields of AuthorDBModel from AuthorBaseModel
+// struct, only when record is still at m.Version otherwise
+// ErrVersionConflict is returned. Record is untouched without any field.
+func (r *PgxAuthorRepository) Patch(ctx context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := patchAuthor(ctx, tx, m, data, fields); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// patchAuthor update given fields of author within tx, only when record is
+// still at m.Version
+func patchAuthor(ctx context.Context, tx pgx.Tx, m *models.AuthorDBModel, data *models.AuthorBaseModel, fields []string) error {
+	values := map[string]any{
+		"name":       data.Name,
+		"email":      data.Email,
+		"birth_date": data.BirthDate,
+		"bio":        data.Bio,
+	}
+
+	set := []string{"version = version + 1"}
+	args := pgx.NamedArgs{"id": m.ID, "version": m.Version}
+	for _, field := range fields {
+		column, ok := authorFields[field]
+		if !ok {
+			return fmt.Errorf("unknown author field %q", field)
+		}
+		set = append(set, column+" = @"+field)
+		args[field] = values[field]
+	}
+
+	query := `UPDATE authors
+	SET ` + strings.Join(set, ", ") + `
+	WHERE id = @id AND version = @version
+	RETURNING name, email, birth_date, bio, version, updated_at`
+
+	before, err := snapshot(ctx, tx, authorAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, query, args).Scan(&m.Name, &m.Email, &m.BirthDate, &m.Bio, &m.Version, &m.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionConflict
+	} else if err != nil {
+		return err
+	}
+
+	_, err = recordDiff(ctx, tx, "update", authorAudit, m.ID, before)
+	return err
+}
+
+// Delete move author record to trash. Books whose primary author is this
+// author are moved along with the same deletion time, so they are restored
+// together.
+func (r *PgxAuthorRepository) Delete(ctx context.Context, m *models.AuthorDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := deleteAuthor(ctx, tx, m); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// deleteAuthor move author record to trash within tx
+func deleteAuthor(ctx context.Context, tx pgx.Tx, m *models.AuthorDBModel) error {
+	before, err := snapshot(ctx, tx, authorAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `UPDATE authors SET deleted_at = now(), version = version + 1
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING deleted_at`, m.ID).Scan(&deletedAt)
+	if err != nil {
+		return err
+	}
+
+	changes, err := recordDiff(ctx, tx, "delete", authorAudit, m.ID, before)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `UPDATE books SET deleted_at = $2, version = version + 1
+	WHERE author_id = $1 AND deleted_at IS NULL
+	RETURNING id`, m.ID, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	// books share the author deletion time, so share its change as well
+	if err := recordEach(ctx, tx, rows, "delete", bookAudit, changes["deleted_at"]); err != nil {
+		return err
+	}
+
+	m.DeletedAt = &deletedAt
+	return nil
+}
+
+// authorBulk author steps of bulk write, update without version is based
+// on the current one
+var authorBulk = bulkWriter[models.AuthorDBModel, models.AuthorBaseModel]{
+	create: insertAuthors,
+	update: func(ctx context.Context, tx pgx.Tx, op AuthorBulkOp) error {
+		if err := lockVersion(ctx, tx, "authors", op.Model.ID, &op.Model.Version); err != nil {
+			return err
+		}
+		fields := op.Fields
+		if fields == nil {
+			fields = authorUpdateFields
+		}
+		return patchAuthor(ctx, tx, op.Model, op.Data, fields)
+	},
+	delete: func(ctx context.Context, tx pgx.Tx, op AuthorBulkOp) error {
+		return deleteAuthor(ctx, tx, op.Model)
+	},
+}
+
+// Bulk write author bulk operations in a single transaction
+func (r *PgxAuthorRepository) Bulk(ctx context.Context, ops []AuthorBulkOp, atomic bool) ([]error, error) {
+	return runBulk(ctx, r.db, authorBulk, ops, atomic)
+}
+
+// Restore bring back author from trash along with books deleted together
+// with the author, pgx.ErrNoRows is returned when author is not in trash
+func (r *PgxAuthorRepository) Restore(ctx context.Context, m *models.AuthorDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `SELECT deleted_at FROM authors
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	FOR UPDATE`, m.ID).Scan(&deletedAt)
+	if err != nil {
+		return err
+	}
+
+	before, err := snapshot(ctx, tx, authorAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE authors SET deleted_at = NULL, version = version + 1 WHERE id = $1", m.ID); err != nil {
+		return err
+	}
+
+	changes, err := recordDiff(ctx, tx, "restore", authorAudit, m.ID, before)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `UPDATE books SET deleted_at = NULL, version = version + 1
+	WHERE author_id = $1 AND deleted_at = $2
+	RETURNING id`, m.ID, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordEach(ctx, tx, rows, "restore", bookAudit, changes["deleted_at"]); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// reload author with its book total
+	return r.Detail(ctx, m)
+}
+
+// Purge permanently remove authors deleted before given time. Books losing a
+// purged author, either removed along with it by foreign key or only losing
+// it as contributor, get their audit event in the same transaction.
+func (r *PgxAuthorRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	// lock purged authors so none of them is restored meanwhile
+	rows, err := tx.Query(ctx, `SELECT id FROM authors
+	WHERE deleted_at < $1
+	ORDER BY id
+	FOR UPDATE`, before)
+	if err != nil {
+		return 0, err
+	}
+	authors, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil || len(authors) == 0 {
+		return 0, err
+	}
+
+	// snapshot their books before the cascade
+	rows, err = tx.Query(ctx, `SELECT id FROM books WHERE author_id = ANY($1)
+	UNION
+	SELECT book_id FROM book_contributors WHERE author_id = ANY($1)
+	ORDER BY 1`, authors)
+	if err != nil {
+		return 0, err
+	}
+	books, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, err
+	}
+
+	snapshots := make([]map[string]any, len(books))
+	for i, id := range books {
+		if snapshots[i], err = snapshot(ctx, tx, bookAudit, id); err != nil {
+			return 0, err
+		}
+	}
+
+	purged, err := purge(ctx, tx, "authors", authorAudit, before)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, id := range books {
+		after, err := snapshot(ctx, tx, bookAudit, id)
+		if err != nil {
+			return 0, err
+		}
+
+		action := "update"
+		if after == nil {
+			action = "purge"
+		}
+		if err := recordEvent(ctx, tx, action, bookAudit, id, models.DiffChanges(snapshots[i], after)); err != nil {
+			return 0, err
+		}
+	}
+
+	return purged, tx.Commit(ctx)
+}
+
+// authorRank full-text search rank of author
+const authorRank = "ts_rank(a.search, websearch_to_tsquery('english', @q))"
+
+// authorSortColumns authors sort field mapped to SQL expression. Missing
+// birth date sorts as infinity, same as PostgreSQL NULL ordering, so it can
+// be compared in keyset predicate.
+var authorSortColumns = map[string]sortColumn{
+	"id":         {expr: "a.id", cast: "int"},
+	"name":       {expr: "a.name", cast: "text"},
+	"birth_date": {expr: "COALESCE(a.birth_date, 'infinity'::date)", cast: "date"},
+	"book_total": {expr: "COUNT(DISTINCT bc.book_id)", cast: "bigint"},
+	"rank":       {expr: authorRank, cast: "real"},
+}
+
+// authorCursorValues keyset values of author for every sort key
+func authorCursorValues(author *models.AuthorDBModel, keys []models.SortKey) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case "id":
+			values[i] = strconv.Itoa(author.ID)
+		case "name":
+			values[i] = author.Name
+		case "birth_date":
+			values[i] = "infinity"
+			if author.BirthDate != nil && author.BirthDate.Valid {
+				values[i] = author.BirthDate.Time.Format(time.DateOnly)
+			}
+		case "book_total":
+			values[i] = strconv.FormatUint(uint64(author.BookTotal), 10)
+		case "rank":
+			if author.Rank != nil {
+				values[i] = strconv.FormatFloat(float64(*author.Rank), 'g', -1, 32)
+			}
+		}
+	}
+
+	return values
+}
+
+// authorSelect authors statement matching filter in its sort order, sort keys
+// are returned along with it
+func authorSelect(filter *models.AuthorFilter) (*selectQuery, []models.SortKey, error) {
+	q := newSelect(authorFrom,
+		"a.id AS id",
+		"a.name AS name",
+		"a.email as email",
+		"a.birth_date AS birth_date",
+		"a.bio AS bio",
+		"a.version AS version",
+		"a.updated_at AS updated_at",
+		"COUNT(DISTINCT bc.book_id) AS book_total",
+		"a.deleted_at AS deleted_at",
+	).GroupBy("a.id").Deleted("a.deleted_at", filter.IncludeDeleted, filter.Trashed)
+
+	// full-text search on name and bio
+	if filter.Search != "" {
+		q.Column(authorRank+" AS rank").
+			Column(`jsonb_build_object(
+		'name', ts_headline('english', a.name, websearch_to_tsquery('english', @q), @headline),
+		'bio', ts_headline('english', coalesce(a.bio, ''), websearch_to_tsquery('english', @q), @headline)
+	) AS highlights`).
+			Where("a.search @@ websearch_to_tsquery('english', @q)").
+			Arg("q", filter.Search).
+			Arg("headline", headlineOptions)
+	}
+
+	if filter.BornBefore != "" {
+		q.Where("a.birth_date < @born_before").Arg("born_before", filter.BornBefore)
+	}
+	if filter.BornAfter != "" {
+		q.Where("a.birth_date > @born_after").Arg("born_after", filter.BornAfter)
+	}
+	if filter.MinBooks != nil {
+		q.Having("COUNT(DISTINCT bc.book_id) >= @min_books").Arg("min_books", *filter.MinBooks)
+	}
+
+	// explicit sort first, best search match by default, newest last tie breaker
+	keys := defaultSort(filter.SortKeys, filter.Search != "")
+	if err := q.Sort(keys, authorSortColumns); err != nil {
+		return nil, nil, err
+	}
+
+	return q, keys, nil
+}
+
+// Fetch get authors database record
+func (r *PgxAuthorRepository) Fetch(ctx context.Context, m *models.FetchAuthorDBModel, filter *models.AuthorFilter) error {
+	q, keys, err := authorSelect(filter)
+	if err != nil {
+		return err
+	}
+
+	var query string
+	if m.Cursor != nil {
+		// keyset mode, continue after cursor position without counting
+		if *m.Cursor != "" {
+			values, err := models.DecodeCursor(*m.Cursor, keys)
+			if err != nil {
+				return err
+			}
+			if err := q.Keyset(keys, authorSortColumns, values); err != nil {
+				return err
+			}
+		}
+
+		// one extra row tells whether next page exists
+		query = q.PagedSQL(m.Limit+1, 0)
+	} else {
+		// we need to get all total record first
+		var total int
+		if err := r.db.Conn.QueryRow(ctx, q.CountSQL(), q.Args()).Scan(&total); err != nil {
+			return err
+		}
+		m.Paginate(total)
+
+		query = q.PagedSQL(m.Limit, m.Offset())
+	}
+
+	rows, err := r.db.Conn.Query(ctx, query, q.Args())
+	if err != nil {
+		return err
+	}
+
+	m.Data, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.AuthorDBModel])
+	if err != nil {
+		return err
+	}
+
+	if m.Cursor != nil && len(m.Data) > m.Limit {
+		m.Data = m.Data[:m.Limit]
+		next := models.EncodeCursor(keys, authorCursorValues(&m.Data[m.Limit-1], keys))
+		m.NextCursor = &next
+	}
+
+	return nil
+}
+
+// Stream iterate every author matching filter in its sort order, rows are read
+// one at a time from the database
+func (r *PgxAuthorRepository) Stream(ctx context.Context, filter *models.AuthorFilter) iter.Seq2[*models.AuthorDBModel, error] {
+	q, _, err := authorSelect(filter)
+	if err != nil {
+		return func(yield func(*models.AuthorDBModel, error) bool) {
+			yield(nil, err)
+		}
+	}
+
+	return streamRows[models.AuthorDBModel](ctx, r.db, q.OrderedSQL(), q.Args())
+}
@@ -0,0 +1,951 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxBookRepository PostgreSQL backed BookRepository
+type PgxBookRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxBookRepository create book repository on top of database pool
+func NewPgxBookRepository(db *database.DbPool) *PgxBookRepository {
+	return &PgxBookRepository{db: db}
+}
+
+// bookColumns columns of book record including its primary author and
+// contributors, book table alias is b and primary author alias is a
+var bookColumns = []string{
+	"b.id AS id",
+	"b.title AS title",
+	"b.isbn AS isbn",
+	"b.description AS description",
+	"b.publish_date AS publish_date",
+	"b.language AS language",
+	"b.page_count AS page_count",
+	"b.format AS format",
+	"b.work_id AS work_id",
+	"b.version AS version",
+	"b.updated_at AS updated_at",
+	"b.deleted_at AS deleted_at",
+	`a.id AS "author.id"`,
+	`a.name AS "author.name"`,
+	`a.email as "author.email"`,
+	`a.birth_date AS "author.birth_date"`,
+	`a.bio AS "author.bio"`,
+	`a.version AS "author.version"`,
+	`a.updated_at AS "author.updated_at"`,
+	`a.deleted_at AS "author.deleted_at"`,
+	`(SELECT count(DISTINCT bc.book_id) FROM book_contributors bc
+	JOIN books cb ON cb.id = bc.book_id AND cb.deleted_at IS NULL
+	WHERE bc.author_id = a.id) AS "author.book_total"`,
+	`(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'author_id', bc.author_id,
+		'name', ca.name,
+		'role', bc.role,
+		'position', bc.position
+	) ORDER BY bc.position, bc.author_id), '[]'::jsonb)
+	FROM book_contributors bc
+	JOIN authors ca ON ca.id = bc.author_id AND ca.deleted_at IS NULL
+	WHERE bc.book_id = b.id) AS contributors`,
+	`(SELECT jsonb_build_object('id', p.id, 'name', p.name)
+	FROM publishers p WHERE p.id = b.publisher_id) AS publisher`,
+	`(SELECT jsonb_build_object('id', s.id, 'name', s.name, 'position', b.series_position)
+	FROM series s WHERE s.id = b.series_id) AS series`,
+	`CASE WHEN b.cover_url IS NOT NULL THEN jsonb_build_object(
+		'url', b.cover_url,
+		'thumbnail_url', b.cover_thumbnail_url
+	) END AS cover`,
+	`(SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', g.id,
+		'slug', g.slug,
+		'name', g.name
+	) ORDER BY g.slug), '[]'::jsonb)
+	FROM book_genres bg
+	JOIN genres g ON g.id = bg.genre_id
+	WHERE bg.book_id = b.id) AS genres`,
+	`(SELECT COALESCE(array_agg(bt.tag ORDER BY bt.tag), '{}')
+	FROM book_tags bt WHERE bt.book_id = b.id) AS tags`,
+}
+
+// bookFrom books table joined with its primary author
+const bookFrom = "books b LEFT JOIN authors a ON a.id = b.author_id"
+
+// insertBook book insert statement, a new work titled after the book is
+// created when work_id is not given
+const insertBook = `WITH work AS (
+		INSERT INTO works (title) SELECT @title WHERE @work_id::int IS NULL RETURNING id
+	)
+	INSERT INTO books (title, isbn, description, publish_date, author_id, publisher_id, language, page_count, format,
+		work_id, series_id, series_position)
+	VALUES (@title, @isbn, @desc, @pubdate, @author_id, @publisher_id, @language, @page_count, @format,
+		COALESCE(@work_id, (SELECT id FROM work)), @series_id, @series_position)
+	RETURNING id, version`
+
+// insertContributor book contributor insert statement
+const insertContributor = `INSERT INTO book_contributors (book_id, author_id, role, position)
+	VALUES ($1, $2, $3, $4)`
+
+// insertGenre book genre insert statement, repeated genre is ignored
+const insertGenre = `INSERT INTO book_genres (book_id, genre_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+// insertTag book tag insert statement
+const insertTag = `INSERT INTO book_tags (book_id, tag) VALUES ($1, $2)`
+
+// Insert add new book record along with its contributors
+func (r *PgxBookRepository) Insert(ctx context.Context, m *models.BookDBModel, data *models.BookBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := insertBooks(ctx, tx, []BookBulkOp{{Action: BulkCreate, Model: m, Data: data}}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// reload book with author and contributors detail
+	return r.Detail(ctx, m)
+}
+
+// insertBooks add book record of every op along with its contributors
+// within tx, inserts and audit events are sent in batches
+func insertBooks(ctx context.Context, tx pgx.Tx, ops []BookBulkOp) error {
+	primaries := make([]int, len(ops))
+	contributors := make([][]models.ContributorBaseModel, len(ops))
+	var authors []int
+	for i, op := range ops {
+		primaries[i], contributors[i] = op.Data.ResolveContributors()
+		for _, contributor := range contributors[i] {
+			authors = append(authors, contributor.AuthorID)
+		}
+	}
+
+	deleted, err := lockAuthors(ctx, tx, authors)
+	if err != nil {
+		return err
+	}
+	for i := range ops {
+		for _, contributor := range contributors[i] {
+			if deleted[contributor.AuthorID] {
+				return &itemError{index: i, err: ErrAuthorDeleted}
+			}
+		}
+	}
+
+	batch := new(itemBatch)
+	for i, op := range ops {
+		m := op.Model
+		batch.Queue(i, func(row pgx.Row) error {
+			return row.Scan(&m.ID, &m.Version)
+		}, insertBook, pgx.NamedArgs{
+			"title":           op.Data.Title,
+			"isbn":            op.Data.NormalizedISBN(),
+			"desc":            op.Data.Desc,
+			"pubdate":         op.Data.PubDate,
+			"author_id":       primaries[i],
+			"publisher_id":    op.Data.PublisherID,
+			"language":        op.Data.Language,
+			"page_count":      op.Data.PageCount,
+			"format":          op.Data.Format,
+			"work_id":         op.Data.WorkID,
+			"series_id":       op.Data.SeriesID,
+			"series_position": op.Data.SeriesPosition,
+		})
+	}
+	if err := batch.Send(ctx, tx); err != nil {
+		return err
+	}
+
+	var slugs []string
+	for _, op := range ops {
+		slugs = append(slugs, op.Data.Genres...)
+	}
+	genres, err := genreIDs(ctx, tx, slugs)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, len(ops))
+	batch = new(itemBatch)
+	for i, op := range ops {
+		ids[i] = op.Model.ID
+		for _, contributor := range contributors[i] {
+			batch.Queue(i, nil, insertContributor, op.Model.ID, contributor.AuthorID, contributor.Role, contributor.Position)
+		}
+		for _, slug := range op.Data.Genres {
+			id, ok := genres[slug]
+			if !ok {
+				return &itemError{index: i, err: fmt.Errorf("%w %s", ErrUnknownGenre, slug)}
+			}
+			batch.Queue(i, nil, insertGenre, op.Model.ID, id)
+		}
+		for _, tag := range models.NormalizeTags(op.Data.Tags) {
+			batch.Queue(i, nil, insertTag, op.Model.ID, tag)
+		}
+	}
+	if err := batch.Send(ctx, tx); err != nil {
+		return err
+	}
+
+	return recordCreates(ctx, tx, bookAudit, ids)
+}
+
+// genreIDs ID of genres by their slug, missing slugs are left out
+func genreIDs(ctx context.Context, tx pgx.Tx, slugs []string) (map[string]int, error) {
+	ids := map[string]int{}
+	if len(slugs) == 0 {
+		return ids, nil
+	}
+
+	rows, err := tx.Query(ctx, "SELECT slug, id FROM genres WHERE slug = ANY($1)", slugs)
+	if err != nil {
+		return nil, err
+	}
+
+	var slug string
+	var id int
+	_, err = pgx.ForEachRow(rows, []any{&slug, &id}, func() error {
+		ids[slug] = id
+		return nil
+	})
+
+	return ids, err
+}
+
+// Detail get single book by ID, deleted book is not found
+func (r *PgxBookRepository) Detail(ctx context.Context, m *models.BookDBModel) error {
+	q := newSelect(bookFrom, bookColumns...).Where("b.id = @id AND b.deleted_at IS NULL").Arg("id", m.ID)
+
+	// run query
+	row, err := r.db.Conn.Query(ctx, q.SQL(), q.Args())
+	if err != nil {
+		return err
+	}
+
+	if err := pgxscan.ScanOne(m, row); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stamp get version and last modification time of book by ID, deleted book
+// is not found
+func (r *PgxBookRepository) Stamp(ctx context.Context, m *models.BookDBModel) error {
+	return r.db.Conn.QueryRow(ctx, `SELECT version, updated_at FROM books
+	WHERE id = $1 AND deleted_at IS NULL`, m.ID).Scan(&m.Version, &m.UpdatedAt)
+}
+
+// FindByTitle get active books matching any of title and primary author
+// keys, title is compared case insensitively
+func (r *PgxBookRepository) FindByTitle(ctx context.Context, keys []models.BookKey) ([]models.BookDBModel, error) {
+	titles := make([]string, len(keys))
+	authors := make([]int, len(keys))
+	for i, key := range keys {
+		titles[i], authors[i] = key.Title, key.AuthorID
+	}
+
+	q := newSelect(bookFrom, bookColumns...).
+		Where("b.deleted_at IS NULL").
+		Where(`(lower(b.title), b.author_id) IN (
+		SELECT lower(k.title), k.author_id FROM unnest(@titles::text[], @authors::int[]) AS k(title, author_id))`).
+		Arg("titles", titles).
+		Arg("authors", authors)
+
+	var books []models.BookDBModel
+	err := pgxscan.Select(ctx, r.db.Conn, &books, q.SQL()+"\n\tORDER BY b.id", q.Args())
+
+	return books, err
+}
+
+// FindByISBN get active books having any of isbns, given as ISBN-13
+func (r *PgxBookRepository) FindByISBN(ctx context.Context, isbns []string) ([]models.BookDBModel, error) {
+	q := newSelect(bookFrom, bookColumns...).
+		Where("b.deleted_at IS NULL AND b.isbn = ANY(@isbns)").
+		Arg("isbns", isbns)
+
+	var books []models.BookDBModel
+	err := pgxscan.Select(ctx, r.db.Conn, &books, q.SQL()+"\n\tORDER BY b.id", q.Args())
+
+	return books, err
+}
+
+// bookFields book fields which can be updated mapped to their column,
+// contributors are kept in their own table
+var bookFields = map[string]string{
+	"title":           "title",
+	"isbn":            "isbn",
+	"description":     "description",
+	"pub_date":        "publish_date",
+	"author_id":       "author_id",
+	"publisher_id":    "publisher_id",
+	"language":        "language",
+	"page_count":      "page_count",
+	"format":          "format",
+	"work_id":         "work_id",
+	"series_id":       "series_id",
+	"series_position": "series_position",
+}
+
+// Update update book record from BookBaseModel struct, only when record is
+// still at m.Version otherwise ErrVersionConflict is returned. Contributors
+// are replaced when given, otherwise only the primary author contributor
+// follow the new author_id.
+func (r *PgxBookRepository) Update(ctx context.Context, m *models.BookDBModel, data *models.BookBaseModel) error {
+	return r.Patch(ctx, m, data, bookUpdateFields(data))
+}
+
+// bookUpdateFields fields written by full update, contributors, work,
+// genres and tags only when given
+func bookUpdateFields(data *models.BookBaseModel) []string {
+	fields := []string{
+		"title", "isbn", "description", "pub_date", "author_id", "publisher_id", "language", "page_count", "format",
+		"series_id", "series_position",
+	}
+	if data.Contributors != nil {
+		fields = append(fields, "contributors")
+	}
+	if data.WorkID != nil {
+		fields = append(fields, "work_id")
+	}
+	if data.Genres != nil {
+		fields = append(fields, "genres")
+	}
+	if data.Tags != nil {
+		fields = append(fields, "tags")
+	}
+
+	return fields
+}
+
+// Patch update only given fields of book record from BookBaseModel struct,
+// only when record is still at m.Version otherwise ErrVersionConflict is
+// returned. Contributors are replaced when listed in fields, otherwise only
+// the primary author contributor follow changed author_id. Record is
+// untouched without any field.
+func (r *PgxBookRepository) Patch(ctx context.Context, m *models.BookDBModel, data *models.BookBaseModel, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := patchBook(ctx, tx, m, data, fields); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// reload book with author and contributors detail
+	return r.Detail(ctx, m)
+}
+
+// patchBook update given fields of book within tx, only when record is
+// still at m.Version
+func patchBook(ctx context.Context, tx pgx.Tx, m *models.BookDBModel, data *models.BookBaseModel, fields []string) error {
+	// lock the book and remember current primary author
+	var previous, version int
+	err := tx.QueryRow(ctx, `SELECT author_id, version FROM books
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE`, m.ID).Scan(&previous, &version)
+	if err != nil {
+		return err
+	} else if version != m.Version {
+		return ErrVersionConflict
+	}
+
+	before, err := snapshot(ctx, tx, bookAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	primary, contributors := data.ResolveContributors()
+
+	// new contributors, or new primary author, must not be in trash
+	var authors []int
+	if slices.Contains(fields, "contributors") {
+		for _, contributor := range contributors {
+			authors = append(authors, contributor.AuthorID)
+		}
+	} else if slices.Contains(fields, "author_id") {
+		authors = append(authors, primary)
+	}
+	deleted, err := lockAuthors(ctx, tx, authors)
+	if err != nil {
+		return err
+	} else if len(deleted) > 0 {
+		return ErrAuthorDeleted
+	}
+
+	values := map[string]any{
+		"title":           data.Title,
+		"isbn":            data.NormalizedISBN(),
+		"description":     data.Desc,
+		"pub_date":        data.PubDate,
+		"author_id":       primary,
+		"publisher_id":    data.PublisherID,
+		"language":        data.Language,
+		"page_count":      data.PageCount,
+		"format":          data.Format,
+		"work_id":         data.WorkID,
+		"series_id":       data.SeriesID,
+		"series_position": data.SeriesPosition,
+	}
+
+	set := []string{"version = version + 1"}
+	args := pgx.NamedArgs{"id": m.ID}
+	for _, field := range fields {
+		// every book stay an edition of some work
+		if field == "contributors" || field == "genres" || field == "tags" || (field == "work_id" && data.WorkID == nil) {
+			continue
+		}
+
+		column, ok := bookFields[field]
+		if !ok {
+			return fmt.Errorf("unknown book field %q", field)
+		}
+		set = append(set, column+" = @"+field)
+		args[field] = values[field]
+	}
+
+	err = tx.QueryRow(ctx, "UPDATE books SET "+strings.Join(set, ", ")+" WHERE id = @id RETURNING version", args).Scan(&m.Version)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(fields, "contributors") {
+		err = replaceContributors(ctx, tx, m.ID, contributors)
+	} else if slices.Contains(fields, "author_id") && previous != primary {
+		err = replacePrimaryAuthor(ctx, tx, m.ID, previous, primary)
+	}
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(fields, "genres") {
+		if err := replaceGenres(ctx, tx, m.ID, data.Genres); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(fields, "tags") {
+		if err := replaceTags(ctx, tx, m.ID, data.Tags); err != nil {
+			return err
+		}
+	}
+
+	_, err = recordDiff(ctx, tx, "update", bookAudit, m.ID, before)
+	return err
+}
+
+// SetCover replace cover of book record, only when record is still at
+// m.Version otherwise ErrVersionConflict is returned. Nil cover remove it.
+// Objects of the replaced cover are returned so they can be removed from
+// storage once the change is committed.
+func (r *PgxBookRepository) SetCover(ctx context.Context, m *models.BookDBModel, cover *models.CoverObjects) (*models.CoverObjects, error) {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	var version int
+	var key, thumbnailKey *string
+	err = tx.QueryRow(ctx, `SELECT version, cover_key, cover_thumbnail_key FROM books
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE`, m.ID).Scan(&version, &key, &thumbnailKey)
+	if err != nil {
+		return nil, err
+	} else if version != m.Version {
+		return nil, ErrVersionConflict
+	}
+
+	before, err := snapshot(ctx, tx, bookAudit, m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cover == nil {
+		cover = new(models.CoverObjects)
+	}
+	// empty objects clear every cover column
+	err = tx.QueryRow(ctx, `UPDATE books
+	SET cover_key = NULLIF(@key, ''),
+	cover_thumbnail_key = NULLIF(@thumbnail_key, ''),
+	cover_url = NULLIF(@url, ''),
+	cover_thumbnail_url = NULLIF(@thumbnail_url, ''),
+	version = version + 1
+	WHERE id = @id
+	RETURNING version`, pgx.NamedArgs{
+		"id":            m.ID,
+		"key":           cover.Key,
+		"thumbnail_key": cover.ThumbnailKey,
+		"url":           cover.URL,
+		"thumbnail_url": cover.ThumbnailURL,
+	}).Scan(&m.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := recordDiff(ctx, tx, "update", bookAudit, m.ID, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	var previous *models.CoverObjects
+	if key != nil && thumbnailKey != nil {
+		previous = &models.CoverObjects{Key: *key, ThumbnailKey: *thumbnailKey}
+	}
+
+	// reload book with its new cover
+	return previous, r.Detail(ctx, m)
+}
+
+// replaceGenres replace every genre of book by their slug
+func replaceGenres(ctx context.Context, tx pgx.Tx, bookID int, slugs []string) error {
+	genres, err := genreIDs(ctx, tx, slugs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM book_genres WHERE book_id = $1", bookID); err != nil {
+		return err
+	}
+
+	batch := new(pgx.Batch)
+	for _, slug := range slugs {
+		id, ok := genres[slug]
+		if !ok {
+			return fmt.Errorf("%w %s", ErrUnknownGenre, slug)
+		}
+		batch.Queue(insertGenre, bookID, id)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// replaceTags replace every tag of book
+func replaceTags(ctx context.Context, tx pgx.Tx, bookID int, tags []string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM book_tags WHERE book_id = $1", bookID); err != nil {
+		return err
+	}
+
+	batch := new(pgx.Batch)
+	for _, tag := range models.NormalizeTags(tags) {
+		batch.Queue(insertTag, bookID, tag)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// lockAuthors lock authors referred by book being written, so none of them is
+// moved to trash (along with its books) before the write is committed. The
+// ones which are already in trash are returned, unknown authors are left to
+// the foreign key.
+func lockAuthors(ctx context.Context, tx pgx.Tx, ids []int) (map[int]bool, error) {
+	deleted := map[int]bool{}
+	if len(ids) == 0 {
+		return deleted, nil
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id, deleted_at IS NOT NULL FROM authors
+	WHERE id = ANY($1)
+	ORDER BY id
+	FOR SHARE`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	var isDeleted bool
+	_, err = pgx.ForEachRow(rows, []any{&id, &isDeleted}, func() error {
+		if isDeleted {
+			deleted[id] = true
+		}
+		return nil
+	})
+
+	return deleted, err
+}
+
+// replaceContributors replace every contributor of book
+func replaceContributors(ctx context.Context, tx pgx.Tx, bookID int, contributors []models.ContributorBaseModel) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM book_contributors WHERE book_id = $1", bookID); err != nil {
+		return err
+	}
+
+	batch := new(pgx.Batch)
+	for _, contributor := range contributors {
+		batch.Queue(insertContributor, bookID, contributor.AuthorID, contributor.Role, contributor.Position)
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// replacePrimaryAuthor move primary author contributor role from previous to
+// current author, other contributors are kept
+func replacePrimaryAuthor(ctx context.Context, tx pgx.Tx, bookID, previous, current int) error {
+	_, err := tx.Exec(ctx, `DELETE FROM book_contributors
+	WHERE book_id = $1 AND author_id = $2 AND role = 'author'`, bookID, previous)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO book_contributors (book_id, author_id, role, position)
+	VALUES ($1, $2, 'author', 0)
+	ON CONFLICT DO NOTHING`, bookID, current)
+	return err
+}
+
+// Delete move book record to trash
+func (r *PgxBookRepository) Delete(ctx context.Context, m *models.BookDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := deleteBook(ctx, tx, m); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// deleteBook move book record to trash within tx
+func deleteBook(ctx context.Context, tx pgx.Tx, m *models.BookDBModel) error {
+	before, err := snapshot(ctx, tx, bookAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `UPDATE books SET deleted_at = now(), version = version + 1
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING deleted_at`, m.ID).Scan(&deletedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := recordDiff(ctx, tx, "delete", bookAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	m.DeletedAt = &deletedAt
+	return nil
+}
+
+// bookBulk book steps of bulk write, update without version is based on the
+// current one
+var bookBulk = bulkWriter[models.BookDBModel, models.BookBaseModel]{
+	create: insertBooks,
+	update: func(ctx context.Context, tx pgx.Tx, op BookBulkOp) error {
+		if err := lockVersion(ctx, tx, "books", op.Model.ID, &op.Model.Version); err != nil {
+			return err
+		}
+		fields := op.Fields
+		if fields == nil {
+			fields = bookUpdateFields(op.Data)
+		}
+		return patchBook(ctx, tx, op.Model, op.Data, fields)
+	},
+	delete: func(ctx context.Context, tx pgx.Tx, op BookBulkOp) error {
+		return deleteBook(ctx, tx, op.Model)
+	},
+}
+
+// Bulk write book bulk operations in a single transaction
+func (r *PgxBookRepository) Bulk(ctx context.Context, ops []BookBulkOp, atomic bool) ([]error, error) {
+	return runBulk(ctx, r.db, bookBulk, ops, atomic)
+}
+
+// Restore bring back book from trash, pgx.ErrNoRows is returned when book is
+// not in trash and ErrAuthorDeleted when its primary author must be restored
+// first
+func (r *PgxBookRepository) Restore(ctx context.Context, m *models.BookDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	var authorDeleted bool
+	err = tx.QueryRow(ctx, `SELECT a.deleted_at IS NOT NULL
+	FROM books b
+	JOIN authors a ON a.id = b.author_id
+	WHERE b.id = $1 AND b.deleted_at IS NOT NULL
+	FOR UPDATE OF b`, m.ID).Scan(&authorDeleted)
+	if err != nil {
+		return err
+	} else if authorDeleted {
+		return ErrAuthorDeleted
+	}
+
+	before, err := snapshot(ctx, tx, bookAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE books SET deleted_at = NULL, version = version + 1 WHERE id = $1", m.ID); err != nil {
+		return err
+	}
+
+	if _, err := recordDiff(ctx, tx, "restore", bookAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// reload book with author and contributors detail
+	return r.Detail(ctx, m)
+}
+
+// Purge permanently remove books deleted before given time
+func (r *PgxBookRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return purge(ctx, r.db.Conn, "books", bookAudit, before)
+}
+
+// bookRank full-text search rank of book, author match weigh less than
+// the book own match
+const bookRank = `(ts_rank(b.search, websearch_to_tsquery('english', @q)) +
+		0.5 * COALESCE(ts_rank(a.search, websearch_to_tsquery('english', @q)), 0))::real`
+
+// bookSortColumns books sort field mapped to SQL expression. Books out of
+// any series sort as infinity position, same as PostgreSQL NULL ordering, so
+// they can be compared in keyset predicate.
+var bookSortColumns = map[string]sortColumn{
+	"id":              {expr: "b.id", cast: "int"},
+	"title":           {expr: "b.title", cast: "text"},
+	"publish_date":    {expr: "b.publish_date", cast: "date"},
+	"rank":            {expr: bookRank, cast: "real"},
+	"series_position": {expr: "COALESCE(b.series_position, 'infinity'::numeric)", cast: "numeric"},
+}
+
+// bookCursorValues keyset values of book for every sort key
+func bookCursorValues(book *models.BookDBModel, keys []models.SortKey) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case "id":
+			values[i] = strconv.Itoa(book.ID)
+		case "title":
+			values[i] = book.Title
+		case "publish_date":
+			if book.PubDate != nil {
+				values[i] = book.PubDate.Time.Format(time.DateOnly)
+			}
+		case "rank":
+			if book.Rank != nil {
+				values[i] = strconv.FormatFloat(float64(*book.Rank), 'g', -1, 32)
+			}
+		case "series_position":
+			values[i] = "infinity"
+			if book.Series != nil {
+				values[i] = strconv.FormatFloat(book.Series.Position, 'f', -1, 64)
+			}
+		}
+	}
+
+	return values
+}
+
+// bookSelect books statement matching filter in its sort order, sort keys
+// are returned along with it
+func bookSelect(filter *models.BookFilter) (*selectQuery, []models.SortKey, error) {
+	q := newSelect(bookFrom, bookColumns...).Deleted("b.deleted_at", filter.IncludeDeleted, filter.Trashed)
+
+	// author filters match books contributed in any role
+	if filter.AuthorID != nil {
+		q.Where(`EXISTS (SELECT 1 FROM book_contributors bc
+		WHERE bc.book_id = b.id AND bc.author_id = @author_id)`).Arg("author_id", *filter.AuthorID)
+	}
+	if len(filter.AuthorIDs) > 0 {
+		q.Where(`EXISTS (SELECT 1 FROM book_contributors bc
+		WHERE bc.book_id = b.id AND bc.author_id = ANY(@author_ids))`).Arg("author_ids", filter.AuthorIDs)
+	}
+
+	// full-text search on title, description, author name and bio
+	if filter.Search != "" {
+		q.Column(bookRank+" AS rank").
+			Column(`jsonb_build_object(
+		'title', ts_headline('english', b.title, websearch_to_tsquery('english', @q), @headline),
+		'description', ts_headline('english', coalesce(b.description, ''), websearch_to_tsquery('english', @q), @headline),
+		'author_name', ts_headline('english', a.name, websearch_to_tsquery('english', @q), @headline)
+	) AS highlights`).
+			Where("(b.search @@ websearch_to_tsquery('english', @q) OR a.search @@ websearch_to_tsquery('english', @q))").
+			Arg("q", filter.Search).
+			Arg("headline", headlineOptions)
+	}
+
+	if len(filter.PublisherIDs) > 0 {
+		q.Where("b.publisher_id = ANY(@publisher_ids)").Arg("publisher_ids", filter.PublisherIDs)
+	}
+	if len(filter.Languages) > 0 {
+		languages := make([]string, len(filter.Languages))
+		for i, language := range filter.Languages {
+			languages[i] = strings.ToLower(language)
+		}
+		q.Where("lower(b.language) = ANY(@languages)").Arg("languages", languages)
+	}
+	if len(filter.Formats) > 0 {
+		q.Where("b.format = ANY(@formats)").Arg("formats", filter.Formats)
+	}
+	if filter.WorkID != nil {
+		q.Where("b.work_id = @work_id").Arg("work_id", *filter.WorkID)
+	}
+
+	if filter.SeriesID != nil {
+		q.Where("b.series_id = @series_id").Arg("series_id", *filter.SeriesID)
+	}
+
+	// genre match its whole subtree
+	if filter.Genre != "" {
+		q.Where(`EXISTS (SELECT 1 FROM book_genres bg
+		WHERE bg.book_id = b.id AND bg.genre_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM genres WHERE slug = @genre
+				UNION
+				SELECT g.id FROM genres g JOIN subtree s ON g.parent_id = s.id
+			)
+			SELECT id FROM subtree
+		))`).Arg("genre", filter.Genre)
+	}
+	// book must have every tag
+	if tags := models.NormalizeTags(filter.Tags); len(tags) > 0 {
+		q.Where(`(SELECT count(*) FROM book_tags bt
+		WHERE bt.book_id = b.id AND bt.tag = ANY(@tags)) = @tag_total`).
+			Arg("tags", tags).
+			Arg("tag_total", len(tags))
+	}
+
+	if filter.PublishedAfter != "" {
+		q.Where("b.publish_date > @published_after").Arg("published_after", filter.PublishedAfter)
+	}
+	if filter.PublishedBefore != "" {
+		q.Where("b.publish_date < @published_before").Arg("published_before", filter.PublishedBefore)
+	}
+
+	// explicit sort first, best search match by default, newest last tie breaker
+	keys := defaultSort(filter.SortKeys, filter.Search != "")
+	if err := q.Sort(keys, bookSortColumns); err != nil {
+		return nil, nil, err
+	}
+
+	return q, keys, nil
+}
+
+// bookFacetLimit most used tags counted in facets
+const bookFacetLimit = "50"
+
+// Fetch get books database record, tag facets are counted over every
+// matching book when requested
+func (r *PgxBookRepository) Fetch(ctx context.Context, m *models.FetchBookDBModel, filter *models.BookFilter) error {
+	q, keys, err := bookSelect(filter)
+	if err != nil {
+		return err
+	}
+
+	if filter.Facets {
+		m.Facets = &models.BookFacets{Tags: []models.TagCount{}}
+		err := pgxscan.Select(ctx, r.db.Conn, &m.Facets.Tags, `SELECT bt.tag AS tag, count(*) AS count
+	FROM book_tags bt
+	WHERE bt.book_id IN (`+q.SelectSQL("b.id")+`)
+	GROUP BY bt.tag
+	ORDER BY count DESC, bt.tag
+	LIMIT `+bookFacetLimit, q.Args())
+		if err != nil {
+			return err
+		}
+	}
+
+	var query string
+	if m.Cursor != nil {
+		// keyset mode, continue after cursor position without counting
+		if *m.Cursor != "" {
+			values, err := models.DecodeCursor(*m.Cursor, keys)
+			if err != nil {
+				return err
+			}
+			if err := q.Keyset(keys, bookSortColumns, values); err != nil {
+				return err
+			}
+		}
+
+		// one extra row tells whether next page exists
+		query = q.PagedSQL(m.Limit+1, 0)
+	} else {
+		// we need to get all total record first
+		var total int
+		if err := r.db.Conn.QueryRow(ctx, q.CountSQL(), q.Args()).Scan(&total); err != nil {
+			return err
+		}
+		m.Paginate(total)
+
+		query = q.PagedSQL(m.Limit, m.Offset())
+	}
+
+	err = pgxscan.Select(ctx, r.db.Conn, &m.Data, query, q.Args())
+	if err != nil {
+		return err
+	}
+
+	if m.Cursor != nil && len(m.Data) > m.Limit {
+		m.Data = m.Data[:m.Limit]
+		next := models.EncodeCursor(keys, bookCursorValues(&m.Data[m.Limit-1], keys))
+		m.NextCursor = &next
+	}
+
+	return nil
+}
+
+// Stream iterate every book matching filter in its sort order, rows are read
+// one at a time from the database
+func (r *PgxBookRepository) Stream(ctx context.Context, filter *models.BookFilter) iter.Seq2[*models.BookDBModel, error] {
+	q, _, err := bookSelect(filter)
+	if err != nil {
+		return func(yield func(*models.BookDBModel, error) bool) {
+			yield(nil, err)
+		}
+	}
+
+	return streamRows[models.BookDBModel](ctx, r.db, q.OrderedSQL(), q.Args())
+}
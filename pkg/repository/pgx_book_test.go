@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestBookSeriesPositionCursor test keyset pagination by series position
+// continue after books out of any series
+func TestBookSeriesPositionCursor(t *testing.T) {
+	keys := []models.SortKey{{Field: "series_position"}}
+	cases := []struct {
+		name     string
+		book     models.BookDBModel
+		position string
+	}{
+		{"in series", models.BookDBModel{ID: 7, Series: &models.BookSeries{ID: 1, Position: 1.5}}, "1.5"},
+		{"no series", models.BookDBModel{ID: 9}, "infinity"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, sortKeys, err := bookSelect(&models.BookFilter{SortKeys: keys})
+			require.NoError(t, err)
+
+			cursor := models.EncodeCursor(sortKeys, bookCursorValues(&tc.book, sortKeys))
+			values, err := models.DecodeCursor(cursor, sortKeys)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.position, strconv.Itoa(tc.book.ID)}, values)
+
+			require.NoError(t, q.Keyset(sortKeys, bookSortColumns, values))
+			assert.Equal(t, tc.position, q.Args()["cursor_0"])
+
+			// NULL position is compared as infinity, so it is never left out
+			query := q.OrderedSQL()
+			assert.Contains(t, query, "(COALESCE(b.series_position, 'infinity'::numeric) > @cursor_0::numeric)")
+			assert.Contains(t, query, "ORDER BY COALESCE(b.series_position, 'infinity'::numeric) ASC")
+			assert.NotContains(t, query, "b.series_position >")
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+)
+
+// itemError error of single bulk operation identified by its index
+type itemError struct {
+	index int
+	err   error
+}
+
+func (e *itemError) Error() string {
+	return e.err.Error()
+}
+
+func (e *itemError) Unwrap() error {
+	return e.err
+}
+
+// itemBatch batch of queries each belonging to a bulk operation, the first
+// failed query is reported as error of its operation
+type itemBatch struct {
+	batch pgx.Batch
+	items []int
+	scans []func(pgx.Row) error
+}
+
+// Queue add query of operation at index item, scan read its returned row
+// and may be nil when query return nothing
+func (b *itemBatch) Queue(item int, scan func(pgx.Row) error, query string, args ...any) {
+	b.batch.Queue(query, args...)
+	b.items = append(b.items, item)
+	b.scans = append(b.scans, scan)
+}
+
+// Send run every queued query in a single round trip within tx
+func (b *itemBatch) Send(ctx context.Context, tx pgx.Tx) error {
+	if b.batch.Len() == 0 {
+		return nil
+	}
+
+	results := tx.SendBatch(ctx, &b.batch)
+	for i, item := range b.items {
+		var err error
+		if b.scans[i] != nil {
+			err = b.scans[i](results.QueryRow())
+		} else {
+			_, err = results.Exec()
+		}
+
+		if err != nil {
+			// rest of the batch is skipped by the server after failure
+			results.Close()
+			return &itemError{index: item, err: err}
+		}
+	}
+
+	return results.Close()
+}
+
+// lockVersion lock active record of table within tx and check it is still
+// at version, zero version is filled with the current one
+func lockVersion(ctx context.Context, tx pgx.Tx, table string, id int, version *int) error {
+	var current int
+	err := tx.QueryRow(ctx, `SELECT version FROM `+table+`
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE`, id).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if *version == 0 {
+		*version = current
+	} else if *version != current {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// bulkWriter entity specific steps of bulk write, create receive every
+// consecutive create operation together so they can be sent in batches
+type bulkWriter[M, D any] struct {
+	create func(ctx context.Context, tx pgx.Tx, ops []BulkOp[M, D]) error
+	update func(ctx context.Context, tx pgx.Tx, op BulkOp[M, D]) error
+	delete func(ctx context.Context, tx pgx.Tx, op BulkOp[M, D]) error
+}
+
+// apply run ops within tx, ops are either all create or a single update or
+// delete operation. Error of update and delete always belong to the
+// operation.
+func (w bulkWriter[M, D]) apply(ctx context.Context, tx pgx.Tx, ops []BulkOp[M, D]) error {
+	var err error
+	switch ops[0].Action {
+	case BulkCreate:
+		return w.create(ctx, tx, ops)
+	case BulkUpdate:
+		err = w.update(ctx, tx, ops[0])
+	case BulkDelete:
+		err = w.delete(ctx, tx, ops[0])
+	default:
+		err = ErrUnknownBulkAction
+	}
+
+	if err != nil {
+		return &itemError{err: err}
+	}
+
+	return nil
+}
+
+// runBulk write ops in a single transaction. All-or-nothing stop at the
+// first failed operation and roll back everything, every other operation
+// get ErrBulkAborted. Best-effort run each operation in its own savepoint
+// and keep the ones which succeed.
+func runBulk[M, D any](ctx context.Context, db *database.DbPool, w bulkWriter[M, D], ops []BulkOp[M, D], atomic bool) ([]error, error) {
+	errs := make([]error, len(ops))
+
+	// Use transaction
+	tx, err := db.Conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	for start := 0; start < len(ops); {
+		if atomic {
+			// consecutive creates are written together
+			end := start + 1
+			for ops[start].Action == BulkCreate && end < len(ops) && ops[end].Action == BulkCreate {
+				end++
+			}
+
+			if err := w.apply(ctx, tx, ops[start:end]); err != nil {
+				var itemErr *itemError
+				if !errors.As(err, &itemErr) {
+					return nil, err
+				}
+
+				for i := range errs {
+					errs[i] = ErrBulkAborted
+				}
+				errs[start+itemErr.index] = itemErr.err
+				return errs, nil
+			}
+
+			start = end
+			continue
+		}
+
+		// nested transaction is a savepoint
+		step, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := w.apply(ctx, step, ops[start:start+1]); err != nil {
+			errs[start] = err
+			var itemErr *itemError
+			if errors.As(err, &itemErr) {
+				errs[start] = itemErr.err
+			}
+
+			if err := step.Rollback(ctx); err != nil {
+				return nil, err
+			}
+		} else if err := step.Commit(ctx); err != nil {
+			return nil, err
+		}
+		start++
+	}
+
+	return errs, tx.Commit(ctx)
+}
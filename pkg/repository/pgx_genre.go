@@ -0,0 +1,179 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxGenreRepository PostgreSQL backed GenreRepository
+type PgxGenreRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxGenreRepository create genre repository on top of database pool
+func NewPgxGenreRepository(db *database.DbPool) *PgxGenreRepository {
+	return &PgxGenreRepository{db: db}
+}
+
+// genreSelect every genre with its path from the root, book total count
+// active books of the genre and all of its descendants
+const genreSelect = `WITH RECURSIVE tree AS (
+		SELECT id, ARRAY[slug::text] AS path FROM genres WHERE parent_id IS NULL
+		UNION ALL
+		SELECT g.id, t.path || g.slug::text FROM genres g JOIN tree t ON g.parent_id = t.id
+	)
+	SELECT
+	g.id AS id,
+	g.slug AS slug,
+	g.name AS name,
+	g.parent_id AS parent_id,
+	t.path AS path,
+	g.version AS version,
+	g.updated_at AS updated_at,
+	(SELECT count(DISTINCT bg.book_id) FROM book_genres bg
+	JOIN books gb ON gb.id = bg.book_id AND gb.deleted_at IS NULL
+	JOIN tree d ON d.id = bg.genre_id
+	WHERE g.slug = ANY(d.path)) AS book_total
+	FROM genres g
+	JOIN tree t ON t.id = g.id`
+
+// Insert add new genre record
+func (r *PgxGenreRepository) Insert(ctx context.Context, m *models.GenreDBModel, data *models.GenreBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `INSERT INTO genres (slug, name, parent_id)
+	VALUES ($1, $2, $3)
+	RETURNING id`, data.Slug, data.Name, data.ParentID).Scan(&m.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := recordCreates(ctx, tx, genreAudit, []int{m.ID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// reload genre with its path
+	return r.Detail(ctx, m)
+}
+
+// Detail get single genre by ID
+func (r *PgxGenreRepository) Detail(ctx context.Context, m *models.GenreDBModel) error {
+	return pgxscan.Get(ctx, r.db.Conn, m, genreSelect+"\n\tWHERE g.id = $1", m.ID)
+}
+
+// List get every genre, ordered by path so each genre follow its parent
+func (r *PgxGenreRepository) List(ctx context.Context) ([]models.GenreDBModel, error) {
+	genres := []models.GenreDBModel{}
+	err := pgxscan.Select(ctx, r.db.Conn, &genres, genreSelect+"\n\tORDER BY t.path")
+	return genres, err
+}
+
+// Update update genre record from GenreBaseModel struct, only when record is
+// still at m.Version otherwise ErrVersionConflict is returned. Moving genre
+// under itself or one of its descendants return ErrGenreCycle.
+func (r *PgxGenreRepository) Update(ctx context.Context, m *models.GenreDBModel, data *models.GenreBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if data.ParentID != nil {
+		// concurrent moves could otherwise form a cycle together
+		if _, err := tx.Exec(ctx, "LOCK TABLE genres IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+
+		var cycle bool
+		err := tx.QueryRow(ctx, `WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM genres WHERE id = $1
+			UNION
+			SELECT g.id, g.parent_id FROM genres g JOIN ancestors a ON g.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`, *data.ParentID, m.ID).Scan(&cycle)
+		if err != nil {
+			return err
+		} else if cycle {
+			return ErrGenreCycle
+		}
+	}
+
+	before, err := snapshot(ctx, tx, genreAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, `UPDATE genres
+	SET slug = @slug, name = @name, parent_id = @parent_id, version = version + 1
+	WHERE id = @id AND version = @version`, pgx.NamedArgs{
+		"id":        m.ID,
+		"version":   m.Version,
+		"slug":      data.Slug,
+		"name":      data.Name,
+		"parent_id": data.ParentID,
+	})
+	if err != nil {
+		return err
+	} else if result.RowsAffected() == 0 {
+		return ErrVersionConflict
+	}
+
+	if _, err := recordDiff(ctx, tx, "update", genreAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// reload genre with its new path
+	return r.Detail(ctx, m)
+}
+
+// Delete permanently remove genre record, it fails with foreign key
+// violation while genre has sub-genres or books
+func (r *PgxGenreRepository) Delete(ctx context.Context, m *models.GenreDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	before, err := snapshot(ctx, tx, genreAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM genres WHERE id = $1", m.ID)
+	if err != nil {
+		return err
+	} else if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err := recordDiff(ctx, tx, "delete", genreAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,218 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxPublisherRepository PostgreSQL backed PublisherRepository
+type PgxPublisherRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxPublisherRepository create publisher repository on top of database
+// pool
+func NewPgxPublisherRepository(db *database.DbPool) *PgxPublisherRepository {
+	return &PgxPublisherRepository{db: db}
+}
+
+// publisherFrom publishers table joined with its books which are not
+// deleted, so book_total only count active books
+const publisherFrom = `publishers p
+	LEFT JOIN books pb ON pb.publisher_id = p.id AND pb.deleted_at IS NULL`
+
+// publisherColumns columns of publisher record including its book total
+var publisherColumns = []string{
+	"p.id AS id",
+	"p.name AS name",
+	"p.website AS website",
+	"p.version AS version",
+	"p.updated_at AS updated_at",
+	"COUNT(pb.id) AS book_total",
+}
+
+// Insert add new publisher record
+func (r *PgxPublisherRepository) Insert(ctx context.Context, m *models.PublisherDBModel, data *models.PublisherBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `INSERT INTO publishers (name, website)
+	VALUES ($1, $2)
+	RETURNING id, name, website, version, updated_at`, data.Name, data.Website).
+		Scan(&m.ID, &m.Name, &m.Website, &m.Version, &m.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordCreates(ctx, tx, publisherAudit, []int{m.ID}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Detail get single publisher by ID
+func (r *PgxPublisherRepository) Detail(ctx context.Context, m *models.PublisherDBModel) error {
+	q := newSelect(publisherFrom, publisherColumns...).Where("p.id = @id").Arg("id", m.ID).GroupBy("p.id")
+
+	return pgxscan.Get(ctx, r.db.Conn, m, q.SQL(), q.Args())
+}
+
+// Update update publisher record from PublisherBaseModel struct, only when
+// record is still at m.Version otherwise ErrVersionConflict is returned
+func (r *PgxPublisherRepository) Update(ctx context.Context, m *models.PublisherDBModel, data *models.PublisherBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	before, err := snapshot(ctx, tx, publisherAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE publishers
+	SET name = @name, website = @website, version = version + 1
+	WHERE id = @id AND version = @version
+	RETURNING name, website, version, updated_at`, pgx.NamedArgs{
+		"id":      m.ID,
+		"version": m.Version,
+		"name":    data.Name,
+		"website": data.Website,
+	}).Scan(&m.Name, &m.Website, &m.Version, &m.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionConflict
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := recordDiff(ctx, tx, "update", publisherAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete permanently remove publisher record, it fails with foreign key
+// violation while any book (even deleted one) is published by it
+func (r *PgxPublisherRepository) Delete(ctx context.Context, m *models.PublisherDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	before, err := snapshot(ctx, tx, publisherAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM publishers WHERE id = $1", m.ID)
+	if err != nil {
+		return err
+	} else if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err := recordDiff(ctx, tx, "delete", publisherAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// publisherSortColumns publishers sort field mapped to SQL expression
+var publisherSortColumns = map[string]sortColumn{
+	"id":         {expr: "p.id", cast: "int"},
+	"name":       {expr: "p.name", cast: "text"},
+	"book_total": {expr: "COUNT(pb.id)", cast: "bigint"},
+}
+
+// publisherCursorValues keyset values of publisher for every sort key
+func publisherCursorValues(publisher *models.PublisherDBModel, keys []models.SortKey) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case "id":
+			values[i] = strconv.Itoa(publisher.ID)
+		case "name":
+			values[i] = publisher.Name
+		case "book_total":
+			values[i] = strconv.FormatUint(uint64(publisher.BookTotal), 10)
+		}
+	}
+
+	return values
+}
+
+// Fetch get publishers database record
+func (r *PgxPublisherRepository) Fetch(ctx context.Context, m *models.FetchPublisherDBModel, filter *models.PublisherFilter) error {
+	q := newSelect(publisherFrom, publisherColumns...).GroupBy("p.id")
+
+	if filter.Search != "" {
+		q.Where("p.name ILIKE '%' || @q || '%'").Arg("q", filter.Search)
+	}
+
+	// explicit sort first, newest last tie breaker
+	keys := defaultSort(filter.SortKeys, false)
+	if err := q.Sort(keys, publisherSortColumns); err != nil {
+		return err
+	}
+
+	var query string
+	if m.Cursor != nil {
+		// keyset mode, continue after cursor position without counting
+		if *m.Cursor != "" {
+			values, err := models.DecodeCursor(*m.Cursor, keys)
+			if err != nil {
+				return err
+			}
+			if err := q.Keyset(keys, publisherSortColumns, values); err != nil {
+				return err
+			}
+		}
+
+		// one extra row tells whether next page exists
+		query = q.PagedSQL(m.Limit+1, 0)
+	} else {
+		// we need to get all total record first
+		var total int
+		if err := r.db.Conn.QueryRow(ctx, q.CountSQL(), q.Args()).Scan(&total); err != nil {
+			return err
+		}
+		m.Paginate(total)
+
+		query = q.PagedSQL(m.Limit, m.Offset())
+	}
+
+	err := pgxscan.Select(ctx, r.db.Conn, &m.Data, query, q.Args())
+	if err != nil {
+		return err
+	}
+
+	if m.Cursor != nil && len(m.Data) > m.Limit {
+		m.Data = m.Data[:m.Limit]
+		next := models.EncodeCursor(keys, publisherCursorValues(&m.Data[m.Limit-1], keys))
+		m.NextCursor = &next
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// PgxSeriesRepository PostgreSQL backed SeriesRepository
+type PgxSeriesRepository struct {
+	db *database.DbPool
+}
+
+// NewPgxSeriesRepository create series repository on top of database pool
+func NewPgxSeriesRepository(db *database.DbPool) *PgxSeriesRepository {
+	return &PgxSeriesRepository{db: db}
+}
+
+// seriesFrom series table joined with its books which are not deleted, so
+// book_total only count active books
+const seriesFrom = `series s
+	LEFT JOIN books sb ON sb.series_id = s.id AND sb.deleted_at IS NULL`
+
+// seriesColumns columns of series record including its book total
+var seriesColumns = []string{
+	"s.id AS id",
+	"s.name AS name",
+	"s.description AS description",
+	"s.version AS version",
+	"s.updated_at AS updated_at",
+	"COUNT(sb.id) AS book_total",
+}
+
+// Insert add new series record
+func (r *PgxSeriesRepository) Insert(ctx context.Context, m *models.SeriesDBModel, data *models.SeriesBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `INSERT INTO series (name, description)
+	VALUES ($1, $2)
+	RETURNING id, name, description, version, updated_at`, data.Name, data.Description).
+		Scan(&m.ID, &m.Name, &m.Description, &m.Version, &m.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordCreates(ctx, tx, seriesAudit, []int{m.ID}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Detail get single series by ID
+func (r *PgxSeriesRepository) Detail(ctx context.Context, m *models.SeriesDBModel) error {
+	q := newSelect(seriesFrom, seriesColumns...).Where("s.id = @id").Arg("id", m.ID).GroupBy("s.id")
+
+	return pgxscan.Get(ctx, r.db.Conn, m, q.SQL(), q.Args())
+}
+
+// Update update series record from SeriesBaseModel struct, only when
+// record is still at m.Version otherwise ErrVersionConflict is returned
+func (r *PgxSeriesRepository) Update(ctx context.Context, m *models.SeriesDBModel, data *models.SeriesBaseModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	before, err := snapshot(ctx, tx, seriesAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE series
+	SET name = @name, description = @description, version = version + 1
+	WHERE id = @id AND version = @version
+	RETURNING name, description, version, updated_at`, pgx.NamedArgs{
+		"id":          m.ID,
+		"version":     m.Version,
+		"name":        data.Name,
+		"description": data.Description,
+	}).Scan(&m.Name, &m.Description, &m.Version, &m.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionConflict
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := recordDiff(ctx, tx, "update", seriesAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete permanently remove series record, it fails with foreign key
+// violation while any book (even deleted one) belongs to it
+func (r *PgxSeriesRepository) Delete(ctx context.Context, m *models.SeriesDBModel) error {
+	// Use transaction
+	tx, err := r.db.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	before, err := snapshot(ctx, tx, seriesAudit, m.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, "DELETE FROM series WHERE id = $1", m.ID)
+	if err != nil {
+		return err
+	} else if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err := recordDiff(ctx, tx, "delete", seriesAudit, m.ID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// seriesSortColumns series sort field mapped to SQL expression
+var seriesSortColumns = map[string]sortColumn{
+	"id":         {expr: "s.id", cast: "int"},
+	"name":       {expr: "s.name", cast: "text"},
+	"book_total": {expr: "COUNT(sb.id)", cast: "bigint"},
+}
+
+// seriesCursorValues keyset values of series for every sort key
+func seriesCursorValues(series *models.SeriesDBModel, keys []models.SortKey) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case "id":
+			values[i] = strconv.Itoa(series.ID)
+		case "name":
+			values[i] = series.Name
+		case "book_total":
+			values[i] = strconv.FormatUint(uint64(series.BookTotal), 10)
+		}
+	}
+
+	return values
+}
+
+// Fetch get series database record
+func (r *PgxSeriesRepository) Fetch(ctx context.Context, m *models.FetchSeriesDBModel, filter *models.SeriesFilter) error {
+	q := newSelect(seriesFrom, seriesColumns...).GroupBy("s.id")
+
+	if filter.Search != "" {
+		q.Where("s.name ILIKE '%' || @q || '%'").Arg("q", filter.Search)
+	}
+
+	// explicit sort first, newest last tie breaker
+	keys := defaultSort(filter.SortKeys, false)
+	if err := q.Sort(keys, seriesSortColumns); err != nil {
+		return err
+	}
+
+	var query string
+	if m.Cursor != nil {
+		// keyset mode, continue after cursor position without counting
+		if *m.Cursor != "" {
+			values, err := models.DecodeCursor(*m.Cursor, keys)
+			if err != nil {
+				return err
+			}
+			if err := q.Keyset(keys, seriesSortColumns, values); err != nil {
+				return err
+			}
+		}
+
+		// one extra row tells whether next page exists
+		query = q.PagedSQL(m.Limit+1, 0)
+	} else {
+		// we need to get all total record first
+		var total int
+		if err := r.db.Conn.QueryRow(ctx, q.CountSQL(), q.Args()).Scan(&total); err != nil {
+			return err
+		}
+		m.Paginate(total)
+
+		query = q.PagedSQL(m.Limit, m.Offset())
+	}
+
+	err := pgxscan.Select(ctx, r.db.Conn, &m.Data, query, q.Args())
+	if err != nil {
+		return err
+	}
+
+	if m.Cursor != nil && len(m.Data) > m.Limit {
+		m.Data = m.Data[:m.Limit]
+		next := models.EncodeCursor(keys, seriesCursorValues(&m.Data[m.Limit-1], keys))
+		m.NextCursor = &next
+	}
+
+	return nil
+}
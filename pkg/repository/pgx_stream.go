@@ -0,0 +1,44 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"iter"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/database"
+)
+
+// streamRows iterate rows of query one at a time as they arrive from the
+// database, so memory stay the same whatever the number of rows. Query or
+// scan error is yielded once and ends the iteration, the connection is
+// released once iteration ends.
+func streamRows[T any](ctx context.Context, db *database.DbPool, query string, args pgx.NamedArgs) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		rows, err := db.Conn.Query(ctx, query, args)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+
+		scanner := pgxscan.NewRowScanner(rows)
+		for rows.Next() {
+			record := new(T)
+			if err := scanner.Scan(record); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// selectQuery SELECT statement builder. Every SQL fragment must come from
+// code, user supplied values only go through named arguments and sort keys
+// are resolved against a whitelist, so the result is safe from injection.
+type selectQuery struct {
+	columns []string
+	from    string
+	where   []string
+	groupBy string
+	having  []string
+	orderBy []string
+	args    pgx.NamedArgs
+}
+
+// newSelect start SELECT statement on from clause (including joins)
+func newSelect(from string, columns ...string) *selectQuery {
+	return &selectQuery{columns: columns, from: from, args: pgx.NamedArgs{}}
+}
+
+// Column add selected column expression
+func (q *selectQuery) Column(expr string) *selectQuery {
+	q.columns = append(q.columns, expr)
+	return q
+}
+
+// Where add condition joined with AND
+func (q *selectQuery) Where(cond string) *selectQuery {
+	q.where = append(q.where, cond)
+	return q
+}
+
+// Deleted scope soft deleted rows by deleted_at column, deleted rows are
+// hidden unless included, trashed only keep deleted rows
+func (q *selectQuery) Deleted(column string, include, trashed bool) *selectQuery {
+	switch {
+	case trashed:
+		return q.Where(column + " IS NOT NULL")
+	case include:
+		return q
+	default:
+		return q.Where(column + " IS NULL")
+	}
+}
+
+// GroupBy set GROUP BY expression
+func (q *selectQuery) GroupBy(expr string) *selectQuery {
+	q.groupBy = expr
+	return q
+}
+
+// Having add aggregate condition joined with AND
+func (q *selectQuery) Having(cond string) *selectQuery {
+	q.having = append(q.having, cond)
+	return q
+}
+
+// OrderBy add ORDER BY expression
+func (q *selectQuery) OrderBy(expr string) *selectQuery {
+	q.orderBy = append(q.orderBy, expr)
+	return q
+}
+
+// Arg set named argument value
+func (q *selectQuery) Arg(name string, value any) *selectQuery {
+	q.args[name] = value
+	return q
+}
+
+// sortColumn sortable field SQL expression, cast is the SQL type cursor
+// value is converted to before compared with the expression
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// defaultSort complete sort keys, best match first when searching without
+// explicit sort and id as last tie breaker so order is always deterministic
+func defaultSort(keys []models.SortKey, search bool) []models.SortKey {
+	if len(keys) == 0 && search {
+		keys = []models.SortKey{{Field: "rank", Desc: true}}
+	}
+
+	for _, key := range keys {
+		if key.Field == "id" {
+			return keys
+		}
+	}
+
+	return append(keys, models.SortKey{Field: "id", Desc: true})
+}
+
+// Sort add ORDER BY for every sort key, fields are resolved into SQL
+// expression from columns whitelist
+func (q *selectQuery) Sort(keys []models.SortKey, columns map[string]sortColumn) error {
+	for _, key := range keys {
+		col, ok := columns[key.Field]
+		if !ok {
+			return fmt.Errorf("unknown sort field %q", key.Field)
+		}
+
+		if key.Desc {
+			q.OrderBy(col.expr + " DESC")
+		} else {
+			q.OrderBy(col.expr + " ASC")
+		}
+	}
+
+	return nil
+}
+
+// Keyset restrict result to rows following cursor values in keys order.
+// Predicate is placed in HAVING on grouped query since sort expression may
+// be an aggregate.
+func (q *selectQuery) Keyset(keys []models.SortKey, columns map[string]sortColumn, values []string) error {
+	if len(keys) != len(values) {
+		return models.ErrInvalidCursor
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... with < for descending keys
+	var or []string
+	for i, key := range keys {
+		col, ok := columns[key.Field]
+		if !ok {
+			return fmt.Errorf("unknown sort field %q", key.Field)
+		}
+
+		var and []string
+		for j := 0; j < i; j++ {
+			prev := columns[keys[j].Field]
+			and = append(and, fmt.Sprintf("%s = @cursor_%d::%s", prev.expr, j, prev.cast))
+		}
+
+		op := ">"
+		if key.Desc {
+			op = "<"
+		}
+		and = append(and, fmt.Sprintf("%s %s @cursor_%d::%s", col.expr, op, i, col.cast))
+
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+		q.Arg(fmt.Sprintf("cursor_%d", i), values[i])
+	}
+
+	predicate := "(" + strings.Join(or, " OR ") + ")"
+	if q.groupBy != "" {
+		q.Having(predicate)
+	} else {
+		q.Where(predicate)
+	}
+
+	return nil
+}
+
+// Args named arguments of the statement
+func (q *selectQuery) Args() pgx.NamedArgs {
+	return q.args
+}
+
+// SQL build statement without ordering and paging
+func (q *selectQuery) SQL() string {
+	return q.build(q.columns)
+}
+
+// SelectSQL build statement selecting given columns instead, without
+// ordering and paging, such as subquery of matching IDs
+func (q *selectQuery) SelectSQL(columns ...string) string {
+	return q.build(columns)
+}
+
+// build compose statement selecting columns
+func (q *selectQuery) build(columns []string) string {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(columns, ",\n\t"))
+	sb.WriteString("\n\tFROM ")
+	sb.WriteString(q.from)
+
+	if len(q.where) > 0 {
+		sb.WriteString("\n\tWHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+	if q.groupBy != "" {
+		sb.WriteString("\n\tGROUP BY ")
+		sb.WriteString(q.groupBy)
+	}
+	if len(q.having) > 0 {
+		sb.WriteString("\n\tHAVING ")
+		sb.WriteString(strings.Join(q.having, " AND "))
+	}
+
+	return sb.String()
+}
+
+// CountSQL build statement counting every matching record, selected columns
+// are left out since only the number of rows matter
+func (q *selectQuery) CountSQL() string {
+	return "SELECT COUNT(*) FROM (" + q.build([]string{"1"}) + ") AS matched"
+}
+
+// PagedSQL build ordered statement returning limit rows after skipping
+// offset rows
+func (q *selectQuery) PagedSQL(limit, offset int) string {
+	q.Arg("limit", limit)
+	q.Arg("offset", offset)
+
+	return q.OrderedSQL() + "\n\tLIMIT @limit OFFSET @offset"
+}
+
+// OrderedSQL build ordered statement returning every matching row
+func (q *selectQuery) OrderedSQL() string {
+	query := q.SQL()
+	if len(q.orderBy) > 0 {
+		query += "\n\tORDER BY " + strings.Join(q.orderBy, ", ")
+	}
+
+	return query
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestKeyset test keyset predicate of every sort key direction, predicate on
+// grouped query is placed in HAVING since it may compare an aggregate
+func TestKeyset(t *testing.T) {
+	cases := []struct {
+		name    string
+		query   *selectQuery
+		keys    []models.SortKey
+		columns map[string]sortColumn
+		values  []string
+		sql     string
+	}{
+		{
+			name:    "mixed directions",
+			query:   newSelect("books b", "b.id"),
+			keys:    []models.SortKey{{Field: "title"}, {Field: "publish_date", Desc: true}, {Field: "id", Desc: true}},
+			columns: bookSortColumns,
+			values:  []string{"Dune", "1965-08-01", "7"},
+			sql: "SELECT b.id\n\tFROM books b\n\tWHERE ((b.title > @cursor_0::text)" +
+				" OR (b.title = @cursor_0::text AND b.publish_date < @cursor_1::date)" +
+				" OR (b.title = @cursor_0::text AND b.publish_date = @cursor_1::date AND b.id < @cursor_2::int))",
+		},
+		{
+			name:    "nullable column",
+			query:   newSelect("authors a", "a.id").Where("a.deleted_at IS NULL"),
+			keys:    []models.SortKey{{Field: "birth_date"}, {Field: "id"}},
+			columns: authorSortColumns,
+			values:  []string{"infinity", "3"},
+			sql: "SELECT a.id\n\tFROM authors a\n\tWHERE a.deleted_at IS NULL AND ((COALESCE(a.birth_date, 'infinity'::date) > @cursor_0::date)" +
+				" OR (COALESCE(a.birth_date, 'infinity'::date) = @cursor_0::date AND a.id > @cursor_1::int))",
+		},
+		{
+			name:    "aggregate column",
+			query:   newSelect("authors a LEFT JOIN book_contributors bc ON bc.author_id = a.id", "a.id").GroupBy("a.id"),
+			keys:    []models.SortKey{{Field: "book_total", Desc: true}, {Field: "id", Desc: true}},
+			columns: authorSortColumns,
+			values:  []string{"12", "3"},
+			sql: "SELECT a.id\n\tFROM authors a LEFT JOIN book_contributors bc ON bc.author_id = a.id\n\tGROUP BY a.id" +
+				"\n\tHAVING ((COUNT(DISTINCT bc.book_id) < @cursor_0::bigint)" +
+				" OR (COUNT(DISTINCT bc.book_id) = @cursor_0::bigint AND a.id < @cursor_1::int))",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.query.Keyset(tc.keys, tc.columns, tc.values))
+			assert.Equal(t, tc.sql, tc.query.SQL())
+
+			for i, value := range tc.values {
+				assert.Equal(t, value, tc.query.Args()[fmt.Sprintf("cursor_%d", i)])
+			}
+		})
+	}
+}
+
+// TestKeysetRejected test cursor values not matching sort keys and unknown
+// sort field
+func TestKeysetRejected(t *testing.T) {
+	q := newSelect("books b", "b.id")
+	err := q.Keyset([]models.SortKey{{Field: "title"}, {Field: "id"}}, bookSortColumns, []string{"Dune"})
+	assert.ErrorIs(t, err, models.ErrInvalidCursor)
+
+	err = q.Keyset([]models.SortKey{{Field: "price"}}, bookSortColumns, []string{"10"})
+	assert.ErrorContains(t, err, `unknown sort field "price"`)
+	assert.Empty(t, q.where)
+}
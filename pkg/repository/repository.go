@@ -0,0 +1,211 @@
+// Package repository Data store contracts used by the API handlers
+package repository
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// ErrVersionConflict tells that record was changed since the version the
+// update is based on
+var ErrVersionConflict = errors.New("record version conflict")
+
+// ErrAuthorDeleted tells that book refers to author which is in trash, book
+// can not be written nor restored until the author is restored
+var ErrAuthorDeleted = errors.New("author is deleted")
+
+// ErrUnknownGenre tells that book refers to genre slug which does not exist
+var ErrUnknownGenre = errors.New("unknown genre")
+
+// ErrGenreCycle tells that genre parent is the genre itself or one of its
+// descendants
+var ErrGenreCycle = errors.New("genre can not be moved under itself or its sub-genres")
+
+// ErrBulkAborted tells that bulk operation was not written since another
+// operation of the same all-or-nothing bulk failed
+var ErrBulkAborted = errors.New("aborted by another failed operation")
+
+// ErrUnknownBulkAction tells that bulk operation action is not supported
+var ErrUnknownBulkAction = errors.New("unknown bulk action")
+
+// Bulk operation actions
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOp single operation of bulk write. Model carries ID of updated or
+// deleted record (and Version when update must be based on it) and is
+// filled with ID and version of the written record. Update only write
+// Fields when given, otherwise it is a full update.
+type BulkOp[M, D any] struct {
+	Action string
+	Model  *M
+	Data   *D
+	Fields []string
+}
+
+// AuthorBulkOp single author bulk operation
+type AuthorBulkOp = BulkOp[models.AuthorDBModel, models.AuthorBaseModel]
+
+// BookBulkOp single book bulk operation
+type BookBulkOp = BulkOp[models.BookDBModel, models.BookBaseModel]
+
+// AuthorRepository author data store contract
+type AuthorRepository interface {
+	// Insert add new author record and fill m with the stored record
+	Insert(ctx context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel) error
+	// Detail fill m with the author record identified by m.ID
+	Detail(ctx context.Context, m *models.AuthorDBModel) error
+	// Stamp fill m version and last modification time of the author record
+	// identified by m.ID
+	Stamp(ctx context.Context, m *models.AuthorDBModel) error
+	// FindByEmail get active author records having any of emails
+	FindByEmail(ctx context.Context, emails []string) ([]models.AuthorDBModel, error)
+	// Update update author record identified by m.ID from data, only when it
+	// is still at m.Version
+	Update(ctx context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel) error
+	// Patch update only given fields of author record identified by m.ID from
+	// data, only when it is still at m.Version
+	Patch(ctx context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel, fields []string) error
+	// Delete move author record identified by m.ID to trash
+	Delete(ctx context.Context, m *models.AuthorDBModel) error
+	// Restore bring back deleted author record identified by m.ID
+	Restore(ctx context.Context, m *models.AuthorDBModel) error
+	// Bulk write every operation in a single transaction, all of them are
+	// rolled back at the first failure when atomic otherwise only failed ones
+	// are skipped. Error of each operation is returned in ops order.
+	Bulk(ctx context.Context, ops []AuthorBulkOp, atomic bool) ([]error, error)
+	// Purge permanently remove author records deleted before given time
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// Fetch fill m with a page of author records matching filter
+	Fetch(ctx context.Context, m *models.FetchAuthorDBModel, filter *models.AuthorFilter) error
+	// Stream iterate every author record matching filter in its sort order
+	// without holding them all in memory
+	Stream(ctx context.Context, filter *models.AuthorFilter) iter.Seq2[*models.AuthorDBModel, error]
+}
+
+// BookRepository book data store contract
+type BookRepository interface {
+	// Insert add new book record and fill m with the stored record
+	Insert(ctx context.Context, m *models.BookDBModel, data *models.BookBaseModel) error
+	// Detail fill m with the book record identified by m.ID
+	Detail(ctx context.Context, m *models.BookDBModel) error
+	// Stamp fill m version and last modification time of the book record
+	// identified by m.ID
+	Stamp(ctx context.Context, m *models.BookDBModel) error
+	// FindByTitle get active book records matching any of keys, title is
+	// compared case insensitively
+	FindByTitle(ctx context.Context, keys []models.BookKey) ([]models.BookDBModel, error)
+	// FindByISBN get active book records having any of isbns, given in
+	// ISBN-13 form
+	FindByISBN(ctx context.Context, isbns []string) ([]models.BookDBModel, error)
+	// Update update book record identified by m.ID from data, only when it
+	// is still at m.Version
+	Update(ctx context.Context, m *models.BookDBModel, data *models.BookBaseModel) error
+	// Patch update only given fields of book record identified by m.ID from
+	// data, only when it is still at m.Version
+	Patch(ctx context.Context, m *models.BookDBModel, data *models.BookBaseModel, fields []string) error
+	// SetCover replace cover of book record identified by m.ID, only when it
+	// is still at m.Version. Nil cover remove it. Objects of the replaced
+	// cover are returned, nil when book had none.
+	SetCover(ctx context.Context, m *models.BookDBModel, cover *models.CoverObjects) (*models.CoverObjects, error)
+	// Delete move book record identified by m.ID to trash
+	Delete(ctx context.Context, m *models.BookDBModel) error
+	// Restore bring back deleted book record identified by m.ID
+	Restore(ctx context.Context, m *models.BookDBModel) error
+	// Bulk write every operation in a single transaction, all of them are
+	// rolled back at the first failure when atomic otherwise only failed ones
+	// are skipped. Error of each operation is returned in ops order.
+	Bulk(ctx context.Context, ops []BookBulkOp, atomic bool) ([]error, error)
+	// Purge permanently remove book records deleted before given time
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// Fetch fill m with a page of book records matching filter
+	Fetch(ctx context.Context, m *models.FetchBookDBModel, filter *models.BookFilter) error
+	// Stream iterate every book record matching filter in its sort order
+	// without holding them all in memory
+	Stream(ctx context.Context, filter *models.BookFilter) iter.Seq2[*models.BookDBModel, error]
+}
+
+// PublisherRepository publisher data store contract
+type PublisherRepository interface {
+	// Insert add new publisher record and fill m with the stored record
+	Insert(ctx context.Context, m *models.PublisherDBModel, data *models.PublisherBaseModel) error
+	// Detail fill m with the publisher record identified by m.ID
+	Detail(ctx context.Context, m *models.PublisherDBModel) error
+	// Update update publisher record identified by m.ID from data, only when
+	// it is still at m.Version
+	Update(ctx context.Context, m *models.PublisherDBModel, data *models.PublisherBaseModel) error
+	// Delete permanently remove publisher record identified by m.ID
+	Delete(ctx context.Context, m *models.PublisherDBModel) error
+	// Fetch fill m with a page of publisher records matching filter
+	Fetch(ctx context.Context, m *models.FetchPublisherDBModel, filter *models.PublisherFilter) error
+}
+
+// SeriesRepository series data store contract
+type SeriesRepository interface {
+	// Insert add new series record and fill m with the stored record
+	Insert(ctx context.Context, m *models.SeriesDBModel, data *models.SeriesBaseModel) error
+	// Detail fill m with the series record identified by m.ID
+	Detail(ctx context.Context, m *models.SeriesDBModel) error
+	// Update update series record identified by m.ID from data, only when it
+	// is still at m.Version
+	Update(ctx context.Context, m *models.SeriesDBModel, data *models.SeriesBaseModel) error
+	// Delete permanently remove series record identified by m.ID
+	Delete(ctx context.Context, m *models.SeriesDBModel) error
+	// Fetch fill m with a page of series records matching filter
+	Fetch(ctx context.Context, m *models.FetchSeriesDBModel, filter *models.SeriesFilter) error
+}
+
+// GenreRepository genre data store contract
+type GenreRepository interface {
+	// Insert add new genre record and fill m with the stored record
+	Insert(ctx context.Context, m *models.GenreDBModel, data *models.GenreBaseModel) error
+	// Detail fill m with the genre record identified by m.ID
+	Detail(ctx context.Context, m *models.GenreDBModel) error
+	// List get every genre, each one after its parent
+	List(ctx context.Context) ([]models.GenreDBModel, error)
+	// Update update genre record identified by m.ID from data, only when it
+	// is still at m.Version. ErrGenreCycle is returned when data.ParentID is
+	// the genre itself or one of its descendants.
+	Update(ctx context.Context, m *models.GenreDBModel, data *models.GenreBaseModel) error
+	// Delete permanently remove genre record identified by m.ID
+	Delete(ctx context.Context, m *models.GenreDBModel) error
+}
+
+// AuditRepository audit event data store contract, events are written by
+// the other repositories within their mutation transaction
+type AuditRepository interface {
+	// Fetch fill m with a page of audit events matching filter, newest first
+	Fetch(ctx context.Context, m *models.FetchAuditDBModel, filter *models.AuditFilter) error
+}
+
+// APIKeyRepository API key data store contract
+type APIKeyRepository interface {
+	// Insert add new API key record and fill m ID and creation time
+	Insert(ctx context.Context, m *models.APIKeyDBModel) error
+	// FindByPrefix fill m with API key identified by m.Prefix
+	FindByPrefix(ctx context.Context, m *models.APIKeyDBModel) error
+	// Touch record usage of API key identified by m.ID
+	Touch(ctx context.Context, m *models.APIKeyDBModel) error
+	// Revoke revoke API key identified by m.Prefix
+	Revoke(ctx context.Context, m *models.APIKeyDBModel) error
+	// List get every API key
+	List(ctx context.Context) ([]models.APIKeyDBModel, error)
+}
+
+// Repositories bundle of every data store used by the application
+type Repositories struct {
+	Author    AuthorRepository
+	Book      BookRepository
+	Audit     AuditRepository
+	APIKey    APIKeyRepository
+	Publisher PublisherRepository
+	Genre     GenreRepository
+	Series    SeriesRepository
+}
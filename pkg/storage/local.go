@@ -0,0 +1,93 @@
+// Package storage Object storage for uploaded files
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage Storage on local filesystem, objects are served by the
+// application itself under Route
+type LocalStorage struct {
+	// Dir directory objects are written to
+	Dir string
+	// Route URL path objects are served under, such as "/files"
+	Route string
+	// BaseURL public origin prepended to object URL, relative URL is used
+	// when empty
+	BaseURL string
+}
+
+// NewLocalStorage create storage writing into dir and served under route
+func NewLocalStorage(dir, route, baseURL string) *LocalStorage {
+	return &LocalStorage{
+		Dir:     dir,
+		Route:   "/" + strings.Trim(route, "/"),
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Put write object into file of key, readers never see partially written
+// file since it is renamed into place
+func (s *LocalStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// Delete remove file of key
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// URL URL of key under Route
+func (s *LocalStorage) URL(key string) string {
+	return s.BaseURL + path.Join(s.Route, key)
+}
+
+// path file of key, key may not escape Dir
+func (s *LocalStorage) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", errors.New("invalid object key " + key)
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
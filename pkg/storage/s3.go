@@ -0,0 +1,205 @@
+// Package storage Object storage for uploaded files
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Storage Storage on S3 compatible object store (AWS S3, MinIO, R2, ...).
+// Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	// Endpoint scheme and host of the service, such as
+	// "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000"
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle address bucket in path instead of host name, required by
+	// most self hosted services
+	PathStyle bool
+	// PublicURL base URL objects are publicly served from, such as CDN in
+	// front of the bucket, object URL is used when empty
+	PublicURL string
+	// Client HTTP client requests are sent with, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Put upload object, objects are immutable once written since every key
+// is derived from its content
+func (s *S3Storage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	sum := sha256.Sum256(data)
+	return s.do(req, hex.EncodeToString(sum[:]))
+}
+
+// Delete remove object, S3 answer success for missing object too
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	return s.do(req, emptyPayloadHash)
+}
+
+// URL public URL of key
+func (s *S3Storage) URL(key string) string {
+	if s.PublicURL != "" {
+		return strings.TrimSuffix(s.PublicURL, "/") + "/" + escapePath(key)
+	}
+
+	return s.objectURL(key)
+}
+
+// objectURL service URL of key
+func (s *S3Storage) objectURL(key string) string {
+	endpoint := strings.TrimSuffix(s.Endpoint, "/")
+	if s.PathStyle {
+		return endpoint + "/" + s.Bucket + "/" + escapePath(key)
+	}
+
+	scheme, host, _ := strings.Cut(endpoint, "://")
+	return scheme + "://" + s.Bucket + "." + host + "/" + escapePath(key)
+}
+
+// do sign and send request, any non 2xx response is an error
+func (s *S3Storage) do(req *http.Request, payloadHash string) error {
+	s.Sign(req, payloadHash, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3 %s %s: %s %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+// emptyPayloadHash SHA-256 of empty payload
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Sign add AWS Signature Version 4 Authorization header to req, every header
+// already set on req is signed along with host. payloadHash is hex SHA-256
+// of request body.
+func (s *S3Storage) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(escapePath(req.URL.Path) + "\n")
+	canonical.WriteString(canonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	digest := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{day, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// hmacSHA256 HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery query sorted by name with every name and value encoded
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(name, true)+"="+escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// escapePath encode object path the way S3 signature expects, slash is kept
+func escapePath(path string) string {
+	return escape(path, false)
+}
+
+// escape percent-encode every byte except unreserved characters, slash is
+// encoded only when escapeSlash is set
+func escape(value string, escapeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+
+	return sb.String()
+}
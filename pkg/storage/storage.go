@@ -0,0 +1,51 @@
+// Package storage Object storage for uploaded files
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kasfil/bookies/pkg/utilities"
+)
+
+// Storage keeps objects by key, implementation must be safe for concurrent
+// use. Keys are slash separated paths such as "covers/1/abc.jpg".
+type Storage interface {
+	// Put write object of key, replacing existing one
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Delete remove object of key, missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// URL public URL object of key is served from
+	URL(key string) string
+}
+
+// NewStorageFromEnv create storage selected by STORAGE_DRIVER, local
+// filesystem by default
+func NewStorageFromEnv() (Storage, error) {
+	switch driver := utilities.EnvString("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return NewLocalStorage(
+			utilities.EnvString("STORAGE_LOCAL_DIR", "uploads"),
+			utilities.EnvString("STORAGE_LOCAL_ROUTE", "/files"),
+			os.Getenv("STORAGE_PUBLIC_URL"),
+		), nil
+	case "s3":
+		s3 := &S3Storage{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    utilities.EnvString("S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: utilities.EnvBool("S3_PATH_STYLE", false),
+			PublicURL: os.Getenv("STORAGE_PUBLIC_URL"),
+		}
+		if s3.Endpoint == "" || s3.Bucket == "" {
+			return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required by s3 storage")
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
@@ -0,0 +1,100 @@
+// Package utilities Utility functions
+package utilities
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvString get environment variable value or fallback when it is not set
+func EnvString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// EnvDuration get environment variable parsed as time.Duration (e.g. "5s"),
+// fallback is returned when the variable is not set or invalid
+func EnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s value %q, using %s", key, value, fallback)
+		return fallback
+	}
+
+	return duration
+}
+
+// EnvFloat get environment variable parsed as float64, fallback is returned
+// when the variable is not set or invalid
+func EnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("invalid %s value %q, using %v", key, value, fallback)
+		return fallback
+	}
+
+	return number
+}
+
+// EnvBool get environment variable parsed as bool ("true", "1", ...),
+// fallback is returned when the variable is not set or invalid
+func EnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid %s value %q, using %t", key, value, fallback)
+		return fallback
+	}
+
+	return flag
+}
+
+// EnvInt get environment variable parsed as int, fallback is returned when
+// the variable is not set or invalid
+func EnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid %s value %q, using %d", key, value, fallback)
+		return fallback
+	}
+
+	return number
+}
+
+// EnvList get comma separated environment variable as trimmed non empty
+// items, nil is returned when the variable is not set
+func EnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -31,10 +31,24 @@ func ParseValidationError(err validator.ValidationErrors) []ValidationErrorMsg {
 		switch fe.Tag() {
 		case "required":
 			errMsg = fmt.Sprintf("%s is required", fe.Field())
+		case "required_without":
+			errMsg = fmt.Sprintf("%s is required when %s is empty", fe.Field(), fe.Param())
+		case "required_with":
+			errMsg = fmt.Sprintf("%s is required when %s is given", fe.Field(), fe.Param())
+		case "oneof":
+			errMsg = fmt.Sprintf("value must be one of %s", fe.Param())
 		case "email":
 			errMsg = "invalid email format"
 		case "validname":
 			errMsg = "invalid name (digit is not allowed)"
+		case "validisbn":
+			errMsg = "invalid ISBN-10 or ISBN-13"
+		case "validslug":
+			errMsg = "invalid slug (lower case letters and digits separated by single hyphen)"
+		case "bcp47_language_tag":
+			errMsg = "invalid BCP-47 language tag"
+		case "url":
+			errMsg = "invalid URL"
 		case "gte":
 			errMsg = fmt.Sprintf("value must be greater or equal than %s", fe.Param())
 		case "lte":
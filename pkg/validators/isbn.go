@@ -0,0 +1,84 @@
+// Package validators Custom validator provider
+package validators
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidISBN ISBN-10 or ISBN-13 validator with checksum check, hyphens and
+// spaces between digits are allowed
+func ValidISBN(fl validator.FieldLevel) bool {
+	_, ok := NormalizeISBN(fl.Field().String())
+	return ok
+}
+
+// NormalizeISBN get ISBN-13 form of ISBN-10 or ISBN-13 value without
+// hyphens and spaces, false when value is not a valid ISBN
+func NormalizeISBN(value string) (string, bool) {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(value))
+
+	switch len(digits) {
+	case 10:
+		if !isbn10Valid(digits) {
+			return "", false
+		}
+		// ISBN-10 is ISBN-13 with 978 prefix and its own check digit
+		isbn := "978" + digits[:9]
+		return isbn + string(isbn13Check(isbn)), true
+	case 13:
+		if !allDigits(digits) || (!strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979")) ||
+			isbn13Check(digits[:12]) != digits[12] {
+			return "", false
+		}
+		return digits, true
+	default:
+		return "", false
+	}
+}
+
+// isbn10Valid check digits and weighted checksum of ISBN-10, last digit may
+// be X standing for 10
+func isbn10Valid(isbn string) bool {
+	sum := 0
+	for i := range 10 {
+		var digit int
+		switch c := isbn[i]; {
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		case c == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * digit
+	}
+
+	return sum%11 == 0
+}
+
+// isbn13Check check digit of the first 12 digits of ISBN-13
+func isbn13Check(isbn string) byte {
+	sum := 0
+	for i := range 12 {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(isbn[i]-'0')
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+// allDigits tells whether value only contains decimal digits
+func allDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,16 @@
+// Package validators Custom validator provider
+package validators
+
+import (
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// slugPattern lower case words of letters and digits joined by hyphen
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidSlug URL friendly identifier validator, such as "science-fiction"
+func ValidSlug(fl validator.FieldLevel) bool {
+	return slugPattern.MatchString(fl.Field().String())
+}
@@ -2,7 +2,8 @@ INSERT INTO public.authors ("name",email,birth_date,bio) VALUES
 ('Lorna M Woodworth','durward1971@gmail.com','1971-03-15','Coffee expert. Avid web maven. Beer enthusiast. Food advocate. Evil alcoholaholic. Passionate zombie fan. Twitter practitioner. Introvert.'),
 ('Charles A Foster','adela1994@gmail.com','1998-02-14','Lifelong beer fan. Proud web aficionado. Social media junkie. Hipster-friendly zombieaholic. Thinker.');
 
-INSERT INTO public.books (title,description,publish_date) VALUES
+-- every book is the single edition of its own work
+WITH data (title,description,publish_date) AS (VALUES
 ('The Alchemist','A young shepherd named Santiago follows his dreams across the world.','1988-01-01'),
 ('The Hobbit','Bilbo Baggins, a hobbit, goes on an unexpected journey with a wizard and thirteen dwarves.','1937-09-21'),
 ('The Lord of the Rings','A fellowship of hobbits, elves, dwarves, men, and wizards band together to destroy the One Ring.','1954-11-29'),
@@ -12,8 +13,7 @@ INSERT INTO public.books (title,description,publish_date) VALUES
 ('The Great Gatsby','A tragic love story set in the Jazz Age, exploring themes of wealth, class, and the American Dream.','1925-04-10'),
 ('The Adventures of Huckleberry Finn','A young boy''s journey down the Mississippi River with a runaway slave.','1885-02-07'),
 ('The Adventures of Tom Sawyer','Tom Sawyer and his friends embark on a series of mischievous adventures.','1876-07-21'),
-('Moby Dick','The obsessive quest of Captain Ahab to hunt and kill the white whale.','1851-11-18');
-INSERT INTO public.books (title,description,publish_date) VALUES
+('Moby Dick','The obsessive quest of Captain Ahab to hunt and kill the white whale.','1851-11-18'),
 ('The Call of the Wild','The story of a domesticated dog who returns to his wild instincts.','1903-07-13'),
 ('Of Mice and Men','A poignant novella about the lives of two migrant workers during the Great Depression.','1937-04-23'),
 ('The Odyssey','The epic journey of Odysseus to return home after the Trojan War.','2000-05-01'),
@@ -21,4 +21,16 @@ INSERT INTO public.books (title,description,publish_date) VALUES
 ('One Hundred Years of Solitude','A magical and epic story of the Buendía family in the fictional town of Macondo.','1967-05-30'),
 ('Crime and Punishment','A psychological thriller exploring themes of guilt, redemption, and the human condition.','1866-01-01'),
 ('Don Quixote','The adventures of a delusional knight-errant and his loyal squire.','1605-01-16'),
-('Hamlet','A tragedy of revenge and indecision by William Shakespeare.','1600-01-01');
+('Hamlet','A tragedy of revenge and indecision by William Shakespeare.','1600-01-01')
+), work AS (
+INSERT INTO public.works (title) SELECT title FROM data RETURNING id, title
+)
+INSERT INTO public.books (title,description,publish_date,work_id)
+SELECT d.title, d.description, d.publish_date::date, w.id FROM data d JOIN work w ON w.title = d.title;
+
+INSERT INTO public.series ("name",description) VALUES
+('Middle-earth','Tales of the Third Age of Middle-earth.');
+
+UPDATE public.books SET series_id = (SELECT id FROM public.series WHERE "name" = 'Middle-earth'),
+series_position = CASE title WHEN 'The Hobbit' THEN 1 ELSE 2 END
+WHERE title IN ('The Hobbit','The Lord of the Rings');
@@ -0,0 +1,26 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestDiffChanges test audit diff only keep changed fields
+func TestDiffChanges(t *testing.T) {
+	before := map[string]any{"id": float64(1), "name": "Tolkien", "bio": nil}
+	after := map[string]any{"id": float64(1), "name": "J. R. R. Tolkien", "bio": nil}
+	assert.Equal(t, map[string]models.AuditChange{
+		"name": {Before: "Tolkien", After: "J. R. R. Tolkien"},
+	}, models.DiffChanges(before, after))
+
+	// created record list every non empty field
+	assert.Equal(t, map[string]models.AuditChange{
+		"id":   {After: float64(1)},
+		"name": {After: "J. R. R. Tolkien"},
+	}, models.DiffChanges(nil, after))
+
+	assert.Empty(t, models.DiffChanges(after, after))
+}
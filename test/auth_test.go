@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// signToken sign token claims with method and key
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// TestJWTVerifierHS256 test HS256 token verification
+func TestJWTVerifierHS256(t *testing.T) {
+	secret := []byte("test-secret")
+	verifier := auth.NewJWTVerifier(auth.JWTConfig{HMACSecret: secret, Issuer: "bookies"})
+
+	token := signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{
+		"sub": "alice", "iss": "bookies", "roles": []string{"editor"}, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	principal, err := verifier.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, &auth.Principal{Subject: "alice", Method: auth.MethodJWT, Roles: []string{"editor"}}, principal)
+
+	// expired
+	token = signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{
+		"sub": "alice", "iss": "bookies", "exp": time.Now().Add(-time.Minute).Unix(),
+	})
+	_, err = verifier.Authenticate(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// wrong issuer
+	token = signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{
+		"sub": "alice", "iss": "other", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	_, err = verifier.Authenticate(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// no expiry
+	token = signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "alice", "iss": "bookies"})
+	_, err = verifier.Authenticate(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+// TestJWTSubjectLength test subject is only accepted while its actor fits the
+// audit log
+func TestJWTSubjectLength(t *testing.T) {
+	secret := []byte("test-secret")
+	verifier := auth.NewJWTVerifier(auth.JWTConfig{HMACSecret: secret})
+
+	cases := []struct {
+		name   string
+		length int
+		valid  bool
+	}{
+		{"longest", auth.MaxSubjectLength, true},
+		{"too long", auth.MaxSubjectLength + 1, false},
+		{"huge", 4096, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			token := signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{
+				"sub": strings.Repeat("a", tc.length), "exp": time.Now().Add(time.Hour).Unix(),
+			})
+			principal, err := verifier.Authenticate(token)
+			if !tc.valid {
+				assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(principal.Actor()), 128)
+		})
+	}
+}
+
+// TestJWTVerifierJWKS test RS256 token verification with JWKS file keys
+func TestJWTVerifierJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	keys, err := auth.LoadJWKS(path)
+	require.NoError(t, err)
+	verifier := auth.NewJWTVerifier(auth.JWTConfig{RSAKeys: keys})
+
+	claims := jwt.MapClaims{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix()}
+	principal, err := verifier.Authenticate(signToken(t, jwt.SigningMethodRS256, "k1", key, claims))
+	require.NoError(t, err)
+	assert.Equal(t, "bob", principal.Subject)
+
+	// unknown key id
+	_, err = verifier.Authenticate(signToken(t, jwt.SigningMethodRS256, "k2", key, claims))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// HS256 is not accepted without shared secret
+	_, err = verifier.Authenticate(signToken(t, jwt.SigningMethodHS256, "k1", []byte("guess"), claims))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+// TestGenerateAPIKey test API key format and hash
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	assert.Regexp(t, "^bk_"+prefix+"_[A-Za-z0-9_-]{43}$", key)
+	assert.Equal(t, auth.HashAPIKey(key), hash)
+}
+
+// TestPrincipalCan test higher role is granted what lower roles are
+func TestPrincipalCan(t *testing.T) {
+	editor := &auth.Principal{Roles: []string{auth.RoleEditor}}
+	assert.True(t, editor.Can(auth.RoleReader))
+	assert.True(t, editor.Can(auth.RoleEditor))
+	assert.False(t, editor.Can(auth.RoleAdmin))
+
+	unknown := &auth.Principal{Roles: []string{"superuser"}}
+	assert.False(t, unknown.Can(auth.RoleReader))
+	assert.False(t, editor.Can("superuser"))
+}
+
+// TestAPIKeyTouchThrottle test API key usage is recorded at most once a minute
+func TestAPIKeyTouchThrottle(t *testing.T) {
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	stale := time.Now().Add(-2 * time.Minute)
+	repo := &fakeAPIKeyRepository{keys: map[string]models.APIKeyDBModel{
+		prefix: {ID: 1, Name: "ci", Prefix: prefix, KeyHash: hash, Roles: []string{auth.RoleReader}, LastUsedAt: &stale},
+	}}
+	authenticator := auth.NewAPIKeyAuthenticator(repo)
+
+	for range 3 {
+		principal, err := authenticator.Authenticate(context.Background(), key)
+		require.NoError(t, err)
+		assert.Equal(t, "ci", principal.Subject)
+	}
+	assert.Equal(t, 1, repo.touches)
+	assert.WithinDuration(t, time.Now(), *repo.keys[prefix].LastUsedAt, time.Second)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -15,17 +16,35 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/kasfil/bookies/pkg/app"
+	"github.com/kasfil/bookies/pkg/auth"
 	"github.com/kasfil/bookies/pkg/database"
+	"github.com/kasfil/bookies/pkg/health"
+	"github.com/kasfil/bookies/pkg/ratelimit"
+	"github.com/kasfil/bookies/pkg/repository"
+	"github.com/kasfil/bookies/pkg/storage"
 	custom_validator "github.com/kasfil/bookies/pkg/validators"
 )
 
 var router *gin.Engine
 
-// TestMain setup for file test
+// TestMain setup for file test. Database backed router is only built when
+// database is configured, tests needing it are skipped otherwise.
 func TestMain(m *testing.M) {
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Unable to find .env file")
+		log.Println("Unable to find .env file")
+	}
+
+	// Register custom validator
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterValidation("validname", custom_validator.ValidName)
+		validate.RegisterValidation("validisbn", custom_validator.ValidISBN)
+		validate.RegisterValidation("validslug", custom_validator.ValidSlug)
+	}
+
+	if os.Getenv("DB_HOST") == "" {
+		log.Println("DB_HOST is not set, skipping database tests")
+		os.Exit(m.Run())
 	}
 
 	ctx := context.Background()
@@ -42,12 +61,10 @@ func TestMain(m *testing.M) {
 		log.Fatal("Failed to connect database", err)
 	}
 
-	// Register custom validator
-	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		validate.RegisterValidation("validname", custom_validator.ValidName)
-	}
-
-	router = app.CreateRestApp()
+	repos := repository.NewPgxRepositories(dbconn)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{})
+	store := storage.NewLocalStorage(filepath.Join(os.TempDir(), "bookies-test"), "/files", "")
+	router = app.CreateRestApp(repos, store, health.NewProbe(dbconn), auth.New(repos.APIKey, nil), limiter)
 
 	host := os.Getenv("APP_HOST")
 	if host == "" {
@@ -57,8 +74,18 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// requireDB skip test needing the database backed router when database is
+// not configured
+func requireDB(t *testing.T) {
+	if router == nil {
+		t.Skip("database is not configured")
+	}
+}
+
 // TestFetchAuthors test getting author
 func TestFetchAuthors(t *testing.T) {
+	requireDB(t)
+
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/authors", nil)
 	router.ServeHTTP(w, req)
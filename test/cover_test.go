@@ -0,0 +1,50 @@
+package test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasfil/bookies/pkg/covers"
+)
+
+// TestProcessCover test image type is detected from content and thumbnail
+// keep aspect ratio
+func TestProcessCover(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 600))
+	img.Set(10, 10, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+
+	cover, err := covers.Process(buf.Bytes(), 200)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", cover.ContentType)
+	assert.Equal(t, ".png", cover.Extension)
+	assert.Equal(t, 400, cover.Width)
+
+	thumbnail, err := jpeg.DecodeConfig(bytes.NewReader(cover.Thumbnail))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, thumbnail.Width)
+	assert.Equal(t, 300, thumbnail.Height)
+
+	original, thumb := covers.Keys(7, cover)
+	assert.True(t, strings.HasPrefix(original, "covers/7/"))
+	assert.True(t, strings.HasSuffix(original, ".png"))
+	assert.True(t, strings.HasSuffix(thumb, "_thumb.jpg"))
+}
+
+// TestProcessCoverRejected test content which is not an accepted image
+func TestProcessCoverRejected(t *testing.T) {
+	_, err := covers.Process([]byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"), 200)
+	assert.ErrorIs(t, err, covers.ErrUnsupportedType)
+
+	// PNG signature without image data
+	_, err = covers.Process([]byte("\x89PNG\r\n\x1a\n"), 200)
+	assert.ErrorIs(t, err, covers.ErrInvalidImage)
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kasfil/bookies/pkg/handlers"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// streamAuthors author repository streaming generated authors
+type streamAuthors struct {
+	repository.AuthorRepository
+	rows int
+	// peak largest heap seen while streaming
+	peak uint64
+}
+
+func (r *streamAuthors) Stream(ctx context.Context, filter *models.AuthorFilter) iter.Seq2[*models.AuthorDBModel, error] {
+	return func(yield func(*models.AuthorDBModel, error) bool) {
+		var stats runtime.MemStats
+		for i := 1; i <= r.rows; i++ {
+			author := &models.AuthorDBModel{ID: i, Name: "Author Number", Email: fmt.Sprintf("author%d@bookies.com", i), Version: 1}
+			if !yield(author, nil) {
+				return
+			}
+
+			if i%5000 == 0 {
+				runtime.ReadMemStats(&stats)
+				r.peak = max(r.peak, stats.HeapAlloc)
+			}
+		}
+	}
+}
+
+// discardWriter response writer dropping the body, so only memory held by
+// the export itself is measured
+type discardWriter struct {
+	header http.Header
+	bytes  int
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) WriteHeader(statusCode int)  {}
+func (w *discardWriter) Flush()                      {}
+func (w *discardWriter) Write(b []byte) (int, error) { w.bytes += len(b); return len(b), nil }
+
+// BenchmarkExportMemory stream exports of growing size, peak-heap-B must
+// stay about the same whatever the number of rows
+func BenchmarkExportMemory(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+
+	for _, rows := range []int{10_000, 100_000, 1_000_000} {
+		for _, format := range []string{models.FormatCSV, models.FormatNDJSON} {
+			b.Run(fmt.Sprintf("%s/%d", format, rows), func(b *testing.B) {
+				repo := &streamAuthors{rows: rows}
+				engine := gin.New()
+				engine.GET("/export/authors", (&handlers.ExportHandler{AuthorRepo: repo}).Authors)
+
+				runtime.GC()
+				var base runtime.MemStats
+				runtime.ReadMemStats(&base)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					w := &discardWriter{header: http.Header{}}
+					engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export/authors?format="+format, nil))
+					b.SetBytes(int64(w.bytes))
+				}
+				b.StopTimer()
+
+				var peak uint64
+				if repo.peak > base.HeapAlloc {
+					peak = repo.peak - base.HeapAlloc
+				}
+				b.ReportMetric(float64(peak), "peak-heap-B")
+			})
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/kasfil/bookies/pkg/app"
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/ratelimit"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// fakeSecret HS256 secret of tokens accepted by fake router
+var fakeSecret = []byte("fake-secret")
+
+// newFakeRouter build application served by in-memory repositories, writes
+// need a token of fakeToken
+func newFakeRouter(repos *repository.Repositories) *gin.Engine {
+	authn := auth.New(repos.APIKey, auth.NewJWTVerifier(auth.JWTConfig{HMACSecret: fakeSecret}))
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{})
+
+	return app.CreateRestApp(repos, nil, nil, authn, limiter)
+}
+
+// fakeToken bearer Authorization header value granting roles
+func fakeToken(t *testing.T, roles ...string) string {
+	return "Bearer " + signToken(t, jwt.SigningMethodHS256, "", fakeSecret, jwt.MapClaims{
+		"sub": "tester", "roles": roles, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+}
+
+// serve send request to router and record its response
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// newJSONRequest create request with JSON body of contentType
+func newJSONRequest(method, path, contentType, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+// fakeAuthorRepository in-memory author store, methods which are not
+// implemented panic through the embedded nil interface
+type fakeAuthorRepository struct {
+	repository.AuthorRepository
+
+	mu      sync.Mutex
+	authors map[int]models.AuthorDBModel
+	nextID  int
+	// writeErr returned by every write instead of storing it
+	writeErr error
+}
+
+// newFakeAuthorRepository create store holding authors, IDs are given in
+// order starting at 1
+func newFakeAuthorRepository(authors ...models.AuthorDBModel) *fakeAuthorRepository {
+	r := &fakeAuthorRepository{authors: map[int]models.AuthorDBModel{}}
+	for _, author := range authors {
+		r.nextID++
+		author.ID, author.Version, author.UpdatedAt = r.nextID, 1, time.Now().UTC().Truncate(time.Second)
+		r.authors[author.ID] = author
+	}
+
+	return r
+}
+
+// write replace author identified by m.ID with data fields, m is filled with
+// the stored record
+func (r *fakeAuthorRepository) write(m *models.AuthorDBModel, data *models.AuthorBaseModel, fields []string) {
+	stored := r.authors[m.ID]
+	set := func(field string) bool { return fields == nil || slices.Contains(fields, field) }
+	if set("name") {
+		stored.Name = data.Name
+	}
+	if set("email") {
+		stored.Email = data.Email
+	}
+	if set("bio") {
+		stored.Bio = data.Bio
+	}
+	if set("birth_date") {
+		stored.BirthDate = nil
+		if data.BirthDate != nil {
+			birthDate, _ := time.Parse(time.DateOnly, *data.BirthDate)
+			stored.BirthDate = &pgtype.Date{Time: birthDate, Valid: true}
+		}
+	}
+	stored.Version++
+	stored.UpdatedAt = stored.UpdatedAt.Add(time.Second)
+
+	r.authors[m.ID] = stored
+	*m = stored
+}
+
+func (r *fakeAuthorRepository) Insert(_ context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writeErr != nil {
+		return r.writeErr
+	}
+
+	r.nextID++
+	m.ID = r.nextID
+	r.authors[m.ID] = models.AuthorDBModel{ID: m.ID, UpdatedAt: time.Now().UTC().Truncate(time.Second)}
+	r.write(m, data, nil)
+	return nil
+}
+
+func (r *fakeAuthorRepository) Detail(_ context.Context, m *models.AuthorDBModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.authors[m.ID]
+	if !ok || stored.DeletedAt != nil {
+		return pgx.ErrNoRows
+	}
+
+	*m = stored
+	return nil
+}
+
+func (r *fakeAuthorRepository) Stamp(ctx context.Context, m *models.AuthorDBModel) error {
+	return r.Detail(ctx, m)
+}
+
+func (r *fakeAuthorRepository) Update(ctx context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel) error {
+	return r.Patch(ctx, m, data, nil)
+}
+
+func (r *fakeAuthorRepository) Patch(_ context.Context, m *models.AuthorDBModel, data *models.AuthorBaseModel, fields []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writeErr != nil {
+		return r.writeErr
+	}
+	if r.authors[m.ID].Version != m.Version {
+		return repository.ErrVersionConflict
+	}
+
+	r.write(m, data, fields)
+	return nil
+}
+
+func (r *fakeAuthorRepository) Delete(_ context.Context, m *models.AuthorDBModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.authors[m.ID]
+	now := time.Now()
+	stored.DeletedAt = &now
+	r.authors[m.ID] = stored
+	return nil
+}
+
+func (r *fakeAuthorRepository) Bulk(ctx context.Context, ops []repository.AuthorBulkOp, atomic bool) ([]error, error) {
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		switch op.Action {
+		case repository.BulkCreate:
+			errs[i] = r.Insert(ctx, op.Model, op.Data)
+		case repository.BulkUpdate:
+			errs[i] = r.Update(ctx, op.Model, op.Data)
+		case repository.BulkDelete:
+			errs[i] = r.Delete(ctx, op.Model)
+		}
+	}
+
+	return errs, nil
+}
+
+func (r *fakeAuthorRepository) Fetch(_ context.Context, m *models.FetchAuthorDBModel, filter *models.AuthorFilter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m.Data = []models.AuthorDBModel{}
+	for id := 1; id <= r.nextID; id++ {
+		stored, ok := r.authors[id]
+
+		// same scoping as deleted_at condition of the SQL query
+		visible := stored.DeletedAt == nil
+		switch {
+		case filter.Trashed:
+			visible = !visible
+		case filter.IncludeDeleted:
+			visible = true
+		}
+
+		if ok && visible {
+			m.Data = append(m.Data, stored)
+		}
+	}
+	m.Paginate(len(m.Data))
+
+	return nil
+}
+
+// fakeBookRepository in-memory book store, methods which are not implemented
+// panic through the embedded nil interface
+type fakeBookRepository struct {
+	repository.BookRepository
+
+	mu    sync.Mutex
+	books map[int]models.BookDBModel
+	// fields given to the last patch
+	fields []string
+	// writeErr returned by every write instead of storing it
+	writeErr error
+}
+
+// newFakeBookRepository create store holding books, IDs are given in order
+// starting at 1
+func newFakeBookRepository(books ...models.BookDBModel) *fakeBookRepository {
+	r := &fakeBookRepository{books: map[int]models.BookDBModel{}}
+	for i, book := range books {
+		book.ID, book.Version, book.UpdatedAt = i+1, 1, time.Now().UTC().Truncate(time.Second)
+		r.books[book.ID] = book
+	}
+
+	return r
+}
+
+func (r *fakeBookRepository) Detail(_ context.Context, m *models.BookDBModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.books[m.ID]
+	if !ok || stored.DeletedAt != nil {
+		return pgx.ErrNoRows
+	}
+
+	*m = stored
+	return nil
+}
+
+func (r *fakeBookRepository) Stamp(ctx context.Context, m *models.BookDBModel) error {
+	return r.Detail(ctx, m)
+}
+
+func (r *fakeBookRepository) Insert(_ context.Context, m *models.BookDBModel, data *models.BookBaseModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writeErr != nil {
+		return r.writeErr
+	}
+
+	m.ID, m.Title, m.Version = len(r.books)+1, data.Title, 1
+	r.books[m.ID] = *m
+	return nil
+}
+
+func (r *fakeBookRepository) Patch(_ context.Context, m *models.BookDBModel, data *models.BookBaseModel, fields []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.books[m.ID]
+	if stored.Version != m.Version {
+		return repository.ErrVersionConflict
+	}
+
+	r.fields = fields
+	stored.Title, stored.Desc, stored.Tags = data.Title, data.Desc, data.Tags
+	stored.Version++
+	stored.UpdatedAt = stored.UpdatedAt.Add(time.Second)
+
+	r.books[m.ID] = stored
+	*m = stored
+	return nil
+}
+
+// fakeAPIKeyRepository in-memory API key store, methods which are not
+// implemented panic through the embedded nil interface
+type fakeAPIKeyRepository struct {
+	repository.APIKeyRepository
+
+	mu   sync.Mutex
+	keys map[string]models.APIKeyDBModel
+	// lookups number of keys looked up by prefix
+	lookups int
+	// touches number of recorded key usages
+	touches int
+}
+
+func (r *fakeAPIKeyRepository) FindByPrefix(_ context.Context, m *models.APIKeyDBModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups++
+	stored, ok := r.keys[m.Prefix]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+
+	*m = stored
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) Touch(_ context.Context, m *models.APIKeyDBModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	stored := r.keys[m.Prefix]
+	stored.LastUsedAt = &now
+	r.keys[m.Prefix] = stored
+	r.touches++
+	return nil
+}
@@ -0,0 +1,22 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestNormalizeTags test tags are trimmed, lower cased and deduplicated
+func TestNormalizeTags(t *testing.T) {
+	tags := models.NormalizeTags([]string{" Space Opera", "classic", "space opera", "", "  ", "CLASSIC"})
+	assert.Equal(t, []string{"space opera", "classic"}, tags)
+	assert.Equal(t, []string{}, models.NormalizeTags(nil))
+}
+
+// TestParseList test ";" separated CSV cell of genres and tags
+func TestParseList(t *testing.T) {
+	assert.Equal(t, []string{"fantasy", "high-fantasy"}, models.ParseList("fantasy; high-fantasy;"))
+	assert.Equal(t, []string{}, models.ParseList(""))
+}
@@ -0,0 +1,233 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// TestAuthorHandler test author create, read, update and delete served by
+// in-memory repository
+func TestAuthorHandler(t *testing.T) {
+	authors := newFakeAuthorRepository()
+	router := newFakeRouter(&repository.Repositories{Author: authors})
+	editor := fakeToken(t, auth.RoleEditor)
+
+	req := newJSONRequest(http.MethodPost, "/authors", gin.MIMEJSON, `{"name": "Ursula Le Guin", "email": "ursula@bookies.com"}`)
+	req.Header.Set("Authorization", editor)
+	w := serve(router, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var created models.AuthorDBModel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, "Ursula Le Guin", created.Name)
+
+	w = serve(router, httptest.NewRequest(http.MethodGet, "/authors/1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	// cached copy is still fresh
+	req = httptest.NewRequest(http.MethodGet, "/authors/1", nil)
+	req.Header.Set("If-None-Match", tag)
+	assert.Equal(t, http.StatusNotModified, serve(router, req).Code)
+
+	// update based on stale copy is refused
+	req = newJSONRequest(http.MethodPut, "/authors/1", gin.MIMEJSON, `{"name": "Ursula K. Le Guin", "email": "ursula@bookies.com"}`)
+	req.Header.Set("Authorization", editor)
+	req.Header.Set("If-Match", `"0-0"`)
+	assert.Equal(t, http.StatusPreconditionFailed, serve(router, req).Code)
+
+	req = newJSONRequest(http.MethodPut, "/authors/1", gin.MIMEJSON, `{"name": "Ursula K. Le Guin", "email": "ursula@bookies.com"}`)
+	req.Header.Set("Authorization", editor)
+	req.Header.Set("If-Match", tag)
+	w = serve(router, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEqual(t, tag, w.Header().Get("ETag"))
+	assert.Equal(t, "Ursula K. Le Guin", authors.authors[1].Name)
+	assert.Equal(t, 2, authors.authors[1].Version)
+
+	// delete is for admins only
+	req = httptest.NewRequest(http.MethodDelete, "/authors/1", nil)
+	req.Header.Set("Authorization", editor)
+	assert.Equal(t, http.StatusForbidden, serve(router, req).Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/authors/1", nil)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleAdmin))
+	assert.Equal(t, http.StatusOK, serve(router, req).Code)
+
+	assert.Equal(t, http.StatusNotFound, serve(router, httptest.NewRequest(http.MethodGet, "/authors/1", nil)).Code)
+}
+
+// TestAuthorHandlerRoles test writes need editor role and valid body
+func TestAuthorHandlerRoles(t *testing.T) {
+	router := newFakeRouter(&repository.Repositories{Author: newFakeAuthorRepository()})
+	body := `{"name": "Octavia Butler", "email": "octavia@bookies.com"}`
+
+	w := serve(router, newJSONRequest(http.MethodPost, "/authors", gin.MIMEJSON, body))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req := newJSONRequest(http.MethodPost, "/authors", gin.MIMEJSON, body)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleReader))
+	assert.Equal(t, http.StatusForbidden, serve(router, req).Code)
+
+	req = newJSONRequest(http.MethodPost, "/authors", gin.MIMEJSON, `{"name": "Octavia Butler"}`)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(router, req).Code)
+}
+
+// TestIncludeDeleted test deleted records are only listed for editors
+func TestIncludeDeleted(t *testing.T) {
+	deleted := time.Now()
+	router := newFakeRouter(&repository.Repositories{Author: newFakeAuthorRepository(
+		models.AuthorDBModel{Name: "Active Author"},
+		models.AuthorDBModel{Name: "Deleted Author", DeletedAt: &deleted},
+	)})
+
+	cases := []struct {
+		name  string
+		token string
+		total int
+	}{
+		{"anonymous", "", 1},
+		{"reader", fakeToken(t, auth.RoleReader), 1},
+		{"editor", fakeToken(t, auth.RoleEditor), 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/authors?include_deleted=true", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+			w := serve(router, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var page models.FetchAuthorDBModel
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			assert.Len(t, page.Data, tc.total)
+		})
+	}
+}
+
+// TestBulkDeleteRoute test bulk delete is only done by admin through its own
+// route
+func TestBulkDeleteRoute(t *testing.T) {
+	authors := newFakeAuthorRepository(models.AuthorDBModel{Name: "First Author"}, models.AuthorDBModel{Name: "Second Author"})
+	router := newFakeRouter(&repository.Repositories{Author: authors})
+
+	// delete item on create and update route is rejected
+	req := newJSONRequest(http.MethodPost, "/authors/bulk", gin.MIMEJSON, `{"items": [{"op": "delete", "id": 1}]}`)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleAdmin))
+	w := serve(router, req)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response models.BulkResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Results[0].Status)
+	assert.Nil(t, authors.authors[1].DeletedAt)
+
+	req = newJSONRequest(http.MethodPost, "/authors/bulk/delete", gin.MIMEJSON, `{"items": [{"id": 1}, {"id": 2}]}`)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+	assert.Equal(t, http.StatusForbidden, serve(router, req).Code)
+
+	req = newJSONRequest(http.MethodPost, "/authors/bulk/delete", gin.MIMEJSON, `{"items": [{"id": 1}, {"id": 2}]}`)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleAdmin))
+	w = serve(router, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotNil(t, authors.authors[1].DeletedAt)
+	assert.NotNil(t, authors.authors[2].DeletedAt)
+
+	// update item is not accepted by delete route
+	req = newJSONRequest(http.MethodPost, "/authors/bulk/delete", gin.MIMEJSON, `{"items": [{"op": "update", "id": 1, "data": {}}]}`)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleAdmin))
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(router, req).Code)
+}
+
+// TestBookWriteDeletedAuthor test book can not be written with author in
+// trash
+func TestBookWriteDeletedAuthor(t *testing.T) {
+	books := newFakeBookRepository()
+	books.writeErr = repository.ErrAuthorDeleted
+	router := newFakeRouter(&repository.Repositories{Book: books})
+
+	req := newJSONRequest(http.MethodPost, "/books", gin.MIMEJSON, `{"title": "The Silmarillion", "pub_date": "1977-09-15", "author_id": "1"}`)
+	req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+	w := serve(router, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"msg": "author is deleted, restore it first"}`, w.Body.String())
+	assert.Empty(t, books.books)
+}
+
+// TestAuthorEmailConflict test every author write report taken email as
+// conflict
+func TestAuthorEmailConflict(t *testing.T) {
+	authors := newFakeAuthorRepository(models.AuthorDBModel{Name: "Ursula Le Guin"})
+	authors.writeErr = &pgconn.PgError{Code: "23505", ConstraintName: "authors_email_key"}
+	router := newFakeRouter(&repository.Repositories{Author: authors})
+
+	cases := []struct {
+		method      string
+		path        string
+		contentType string
+	}{
+		{http.MethodPost, "/authors", gin.MIMEJSON},
+		{http.MethodPut, "/authors/1", gin.MIMEJSON},
+		{http.MethodPatch, "/authors/1", "application/merge-patch+json"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.method, func(t *testing.T) {
+			req := newJSONRequest(tc.method, tc.path, tc.contentType, `{"name": "Ursula Le Guin", "email": "taken@bookies.com"}`)
+			req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+			w := serve(router, req)
+			assert.Equal(t, http.StatusConflict, w.Code)
+			assert.JSONEq(t, `{"msg": "email already registered"}`, w.Body.String())
+		})
+	}
+}
+
+// TestMalformedBody test body of wrong type is rejected instead of written
+// as empty record
+func TestMalformedBody(t *testing.T) {
+	authors := newFakeAuthorRepository(models.AuthorDBModel{Name: "Ursula Le Guin"})
+	books := newFakeBookRepository(models.BookDBModel{Title: "The Dispossessed"})
+	router := newFakeRouter(&repository.Repositories{Author: authors, Book: books})
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"add author", http.MethodPost, "/authors", `{"name": 42, "email": "ursula@bookies.com"}`},
+		{"update author", http.MethodPut, "/authors/1", `{"name": 42, "email": "ursula@bookies.com"}`},
+		{"add book", http.MethodPost, "/books", `{"title": "The Lathe of Heaven", "pub_date": "1971-01-01", "author_id": "1", "page_count": "x"}`},
+		{"update book", http.MethodPut, "/books/1", `{"title": "The Dispossessed", "pub_date": "1974-05-01", "author_id": "1", "page_count": "x"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newJSONRequest(tc.method, tc.path, gin.MIMEJSON, tc.body)
+			req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+			assert.Equal(t, http.StatusUnprocessableEntity, serve(router, req).Code)
+		})
+	}
+
+	assert.Equal(t, "Ursula Le Guin", authors.authors[1].Name)
+	assert.Equal(t, 1, authors.authors[1].Version)
+	assert.Equal(t, "The Dispossessed", books.books[1].Title)
+	assert.Len(t, books.books, 1)
+}
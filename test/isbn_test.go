@@ -0,0 +1,40 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/validators"
+)
+
+// TestNormalizeISBN test ISBN checksum and ISBN-13 normalization
+func TestNormalizeISBN(t *testing.T) {
+	cases := []struct {
+		value string
+		isbn  string
+		ok    bool
+	}{
+		{"978-0-261-10221-7", "9780261102217", true},
+		{"0-261-10221-4", "9780261102217", true},
+		{"080442957X", "9780804429573", true},
+		{"080442957x", "9780804429573", true},
+		{"979 10 90636 07 1", "9791090636071", true},
+		{"9780261102218", "", false},
+		{"0261102215", "", false},
+		{"X261102214", "", false},
+		{"9770261102217", "", false},
+		{"978026110221", "", false},
+	}
+
+	for _, c := range cases {
+		isbn, ok := validators.NormalizeISBN(c.value)
+		assert.Equal(t, c.ok, ok, c.value)
+		assert.Equal(t, c.isbn, isbn, c.value)
+	}
+
+	isbn := "0-261-10221-4"
+	book := models.BookBaseModel{ISBN: &isbn}
+	assert.Equal(t, "9780261102217", *book.NormalizedISBN())
+}
@@ -0,0 +1,44 @@
+package test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestCursor test cursor round-trip and rejection of cursor which does not
+// belong to the requested sort
+func TestCursor(t *testing.T) {
+	keys := []models.SortKey{{Field: "title"}, {Field: "id", Desc: true}}
+	cursor := models.EncodeCursor(keys, []string{"Dune, Part 1", "42"})
+
+	values, err := models.DecodeCursor(cursor, keys)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Dune, Part 1", "42"}, values)
+
+	cases := []struct {
+		name   string
+		cursor string
+		keys   []models.SortKey
+	}{
+		{"other sort field", cursor, []models.SortKey{{Field: "publish_date"}, {Field: "id", Desc: true}}},
+		{"other sort direction", cursor, []models.SortKey{{Field: "title", Desc: true}, {Field: "id", Desc: true}}},
+		{"fewer sort keys", cursor, []models.SortKey{{Field: "id", Desc: true}}},
+		{"garbage", "!!not-a-cursor!!", keys},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("title:asc")), keys},
+		{"tampered sort", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"title:asc","v":["a","1"]}`)), keys},
+		{"tampered values", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"title:asc,id:desc","v":["a"]}`)), keys},
+		{"truncated", cursor[:len(cursor)-3], keys},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := models.DecodeCursor(tc.cursor, tc.keys)
+			assert.ErrorIs(t, err, models.ErrInvalidCursor)
+		})
+	}
+}
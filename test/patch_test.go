@@ -0,0 +1,202 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/handlers"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// TestBaseModel test record converted back into request body shape
+func TestBaseModel(t *testing.T) {
+	birthDate := pgtype.Date{Time: time.Date(1892, 1, 3, 0, 0, 0, 0, time.UTC), Valid: true}
+	author := models.AuthorDBModel{ID: 1, Name: "Tolkien", Email: "jrr@tolkien.com", BirthDate: &birthDate}
+	base := author.Base()
+	assert.Equal(t, "Tolkien", base.Name)
+	assert.Equal(t, "1892-01-03", *base.BirthDate)
+	assert.Nil(t, base.Bio)
+
+	book := models.BookDBModel{
+		Title:   "The Hobbit",
+		PubDate: &pgtype.Date{Time: time.Date(1937, 9, 21, 0, 0, 0, 0, time.UTC), Valid: true},
+		Author:  author,
+		Contributors: []models.Contributor{
+			{AuthorID: 1, Name: "Tolkien", Role: "author", Position: 0},
+			{AuthorID: 2, Name: "Baynes", Role: "illustrator", Position: 1},
+		},
+		Publisher: &models.Publisher{ID: 4, Name: "Allen & Unwin"},
+		WorkID:    9,
+	}
+	bookBase := book.Base()
+	assert.Equal(t, "1937-09-21", bookBase.PubDate)
+	assert.Equal(t, "1", bookBase.AuthorID)
+	assert.Len(t, bookBase.Contributors, 2)
+	assert.Equal(t, 1, *bookBase.Contributors[1].Position)
+	assert.Equal(t, 4, *bookBase.PublisherID)
+	assert.Equal(t, 9, *bookBase.WorkID)
+	assert.Nil(t, bookBase.Format)
+
+	// record converted back resolve to the same contributors
+	primary, contributors := bookBase.ResolveContributors()
+	assert.Equal(t, 1, primary)
+	assert.Equal(t, bookBase.Contributors, contributors)
+}
+
+// patchedBook book record before every patch case
+func patchedBook() models.BookDBModel {
+	desc := "There and back again"
+	return models.BookDBModel{
+		Title:   "The Hobbit",
+		Desc:    &desc,
+		PubDate: &pgtype.Date{Time: time.Date(1937, 9, 21, 0, 0, 0, 0, time.UTC), Valid: true},
+		Author:  models.AuthorDBModel{ID: 1, Name: "Tolkien"},
+		Tags:    []string{"fantasy", "classic"},
+	}
+}
+
+// jsonMap record as JSON object, the shape audit snapshot diff compare
+func jsonMap(t *testing.T, v any) map[string]any {
+	raw, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(raw, &m))
+	return m
+}
+
+// TestPatchBook test merge-patch and JSON Patch documents only write and
+// audit the fields they change
+func TestPatchBook(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		fields      []string
+		check       func(t *testing.T, book models.BookDBModel)
+	}{
+		{
+			name:        "merge-patch null remove field",
+			contentType: handlers.MergePatchType,
+			body:        `{"description": null}`,
+			fields:      []string{"description"},
+			check: func(t *testing.T, book models.BookDBModel) {
+				assert.Nil(t, book.Desc)
+				assert.Equal(t, "The Hobbit", book.Title)
+			},
+		},
+		{
+			name:        "merge-patch replace field",
+			contentType: handlers.MergePatchType,
+			body:        `{"title": "The Hobbit, or There and Back Again", "tags": ["fantasy", "classic"]}`,
+			fields:      []string{"title"},
+			check: func(t *testing.T, book models.BookDBModel) {
+				assert.Equal(t, "The Hobbit, or There and Back Again", book.Title)
+				assert.Equal(t, "There and back again", *book.Desc)
+			},
+		},
+		{
+			name:        "json-patch add to array",
+			contentType: handlers.JSONPatchType,
+			body:        `[{"op": "add", "path": "/tags/-", "value": "adventure"}]`,
+			fields:      []string{"tags"},
+			check: func(t *testing.T, book models.BookDBModel) {
+				assert.Equal(t, []string{"fantasy", "classic", "adventure"}, book.Tags)
+			},
+		},
+		{
+			name:        "json-patch remove from array",
+			contentType: handlers.JSONPatchType,
+			body:        `[{"op": "remove", "path": "/tags/0"}]`,
+			fields:      []string{"tags"},
+			check: func(t *testing.T, book models.BookDBModel) {
+				assert.Equal(t, []string{"classic"}, book.Tags)
+			},
+		},
+		{
+			name:        "json-patch replace array item",
+			contentType: handlers.JSONPatchType,
+			body:        `[{"op": "test", "path": "/tags/1", "value": "classic"}, {"op": "replace", "path": "/tags/1", "value": "children"}]`,
+			fields:      []string{"tags"},
+			check: func(t *testing.T, book models.BookDBModel) {
+				assert.Equal(t, []string{"fantasy", "children"}, book.Tags)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			books := newFakeBookRepository(patchedBook())
+			router := newFakeRouter(&repository.Repositories{Book: books})
+			before := books.books[1]
+
+			req := newJSONRequest(http.MethodPatch, "/books/1", tc.contentType, tc.body)
+			req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+			w := serve(router, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			after := books.books[1]
+			assert.Equal(t, tc.fields, books.fields)
+			assert.Equal(t, 2, after.Version)
+			tc.check(t, after)
+
+			// audit diff of the written record only list the changed fields
+			changes := models.DiffChanges(jsonMap(t, before.Base()), jsonMap(t, after.Base()))
+			changed := make([]string, 0, len(changes))
+			for field := range changes {
+				changed = append(changed, field)
+			}
+			slices.Sort(changed)
+			assert.Equal(t, tc.fields, changed)
+		})
+	}
+}
+
+// TestPatchBookRejected test patch which can not be applied leave the record
+// untouched
+func TestPatchBookRejected(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		ifMatch     string
+		body        string
+		status      int
+	}{
+		{"failed test op", handlers.JSONPatchType, "", `[{"op": "test", "path": "/title", "value": "Dune"}, {"op": "replace", "path": "/title", "value": "Dune"}]`, http.StatusConflict},
+		{"unsupported content type", "text/plain", "", `{"title": "Dune"}`, http.StatusUnsupportedMediaType},
+		{"stale version", handlers.MergePatchType, `"1-0"`, `{"title": "Dune"}`, http.StatusPreconditionFailed},
+		{"invalid patched record", handlers.MergePatchType, "", `{"title": null}`, http.StatusUnprocessableEntity},
+		{"unknown field", handlers.JSONPatchType, "", `[{"op": "add", "path": "/price", "value": 10}]`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			books := newFakeBookRepository(patchedBook())
+			router := newFakeRouter(&repository.Repositories{Book: books})
+
+			req := newJSONRequest(http.MethodPatch, "/books/1", tc.contentType, tc.body)
+			req.Header.Set("Authorization", fakeToken(t, auth.RoleEditor))
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			w := serve(router, req)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+			assert.Equal(t, patchedBook().Title, books.books[1].Title)
+			assert.Equal(t, 1, books.books[1].Version)
+			assert.Nil(t, books.fields)
+
+			if tc.status == http.StatusUnsupportedMediaType {
+				assert.Equal(t, handlers.MergePatchType+", "+handlers.JSONPatchType, w.Header().Get("Accept-Patch"))
+			}
+		})
+	}
+}
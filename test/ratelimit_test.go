@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasfil/bookies/pkg/app"
+	"github.com/kasfil/bookies/pkg/auth"
+	"github.com/kasfil/bookies/pkg/models"
+	"github.com/kasfil/bookies/pkg/ratelimit"
+	"github.com/kasfil/bookies/pkg/repository"
+)
+
+// TestMemoryStore test token bucket refill and denial
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	for i := 1; i >= 0; i-- {
+		result, err := store.Take(ctx, "client", limit, now)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := store.Take(ctx, "client", limit, now)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 2*time.Second, result.Reset)
+
+	// other client has its own bucket
+	result, _ = store.Take(ctx, "other", limit, now)
+	assert.True(t, result.Allowed)
+
+	// one token refilled after a second
+	result, _ = store.Take(ctx, "client", limit, now.Add(time.Second))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+// TestParseLimit test RATE,BURST limit format
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("10,20")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Rate: 10, Burst: 20}, limit)
+
+	limit, err = ratelimit.ParseLimit("0.5")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Rate: 0.5, Burst: 1}, limit)
+
+	limit, err = ratelimit.ParseLimit("0")
+	require.NoError(t, err)
+	assert.False(t, limit.Enabled())
+
+	_, err = ratelimit.ParseLimit("fast,20")
+	assert.Error(t, err)
+}
+
+// TestForwardedForSpoofing test anonymous client cannot get a fresh bucket by
+// sending a new X-Forwarded-For, unless the request come through a trusted
+// proxy
+func TestForwardedForSpoofing(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxies string
+		second  int
+	}{
+		{"no trusted proxy", "", http.StatusTooManyRequests},
+		{"trusted proxy", "192.0.2.1", http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tc.proxies)
+			limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 1})
+			throttled := app.CreateRestApp(&repository.Repositories{}, nil, nil, auth.New(nil, nil), limiter)
+
+			codes := []int{}
+			for _, forwarded := range []string{"203.0.113.1", "203.0.113.2"} {
+				w := httptest.NewRecorder()
+				// invalid page is rejected before reaching the repository
+				req := httptest.NewRequest(http.MethodGet, "/authors?page=0", nil)
+				req.RemoteAddr = "192.0.2.1:40000"
+				req.Header.Set("X-Forwarded-For", forwarded)
+				throttled.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+
+			assert.Equal(t, []int{http.StatusUnprocessableEntity, tc.second}, codes)
+		})
+	}
+}
+
+// TestInvalidCredentialsThrottled test invalid credentials are throttled by
+// client IP before they are looked up
+func TestInvalidCredentialsThrottled(t *testing.T) {
+	keys := &fakeAPIKeyRepository{keys: map[string]models.APIKeyDBModel{}}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 1})
+	throttled := app.CreateRestApp(&repository.Repositories{APIKey: keys}, nil, nil, auth.New(keys, nil), limiter)
+
+	codes := []int{}
+	for _, key := range []string{"bk_0001_guess", "bk_0002_guess", "bk_0003_guess"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/authors", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		throttled.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes)
+	assert.Equal(t, 1, keys.lookups)
+}
@@ -0,0 +1,29 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestParseSort test sort param parsing and whitelist
+func TestParseSort(t *testing.T) {
+	keys, err := models.ParseSort("title,-publish_date", models.BookSortFields)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SortKey{{Field: "title"}, {Field: "publish_date", Desc: true}}, keys)
+
+	keys, err = models.ParseSort("name:asc,book_total:desc", models.AuthorSortFields)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SortKey{{Field: "name"}, {Field: "book_total", Desc: true}}, keys)
+
+	_, err = models.ParseSort("title;DROP TABLE books", models.BookSortFields)
+	assert.Error(t, err)
+
+	_, err = models.ParseSort("title:up", models.BookSortFields)
+	assert.Error(t, err)
+
+	_, err = models.ParseSort("title,title", models.BookSortFields)
+	assert.Error(t, err)
+}
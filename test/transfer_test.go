@@ -0,0 +1,40 @@
+package test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasfil/bookies/pkg/models"
+)
+
+// TestContributorsCell test contributors CSV cell round trip
+func TestContributorsCell(t *testing.T) {
+	contributors, err := models.ParseContributors("1:author; 2:illustrator")
+	assert.NoError(t, err)
+	assert.Len(t, contributors, 2)
+	assert.Equal(t, 2, contributors[1].AuthorID)
+	assert.Equal(t, "illustrator", contributors[1].Role)
+	assert.Equal(t, 1, *contributors[1].Position)
+	assert.Equal(t, "1:author;2:illustrator", models.FormatContributors(contributors))
+
+	_, err = models.ParseContributors("tolkien")
+	assert.Error(t, err)
+}
+
+// TestCSVRecord test CSV rows follow their columns
+func TestCSVRecord(t *testing.T) {
+	author := models.AuthorDBModel{ID: 1, Name: "Tolkien", Email: "jrr@tolkien.com", BookTotal: 2}
+	assert.Len(t, author.CSVRecord(), len(models.AuthorCSVColumns))
+	assert.Equal(t, []string{"1", "Tolkien", "jrr@tolkien.com", "", "", "2"}, author.CSVRecord()[:6])
+
+	book := models.BookDBModel{ID: 3, Title: "The Hobbit", Author: author}
+	assert.Len(t, book.CSVRecord(), len(models.BookCSVColumns))
+
+	// series position keep its fraction
+	book.Series = &models.BookSeries{ID: 4, Name: "Middle-earth", Position: 1.5}
+	record := book.CSVRecord()
+	assert.Equal(t, "4", record[slices.Index(models.BookCSVColumns, "series_id")])
+	assert.Equal(t, "1.5", record[slices.Index(models.BookCSVColumns, "series_position")])
+}